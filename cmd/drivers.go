@@ -0,0 +1,7 @@
+package cmd
+
+import (
+	// drivers of the sql metrics
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
@@ -1,20 +1,58 @@
 package cmd
 
 import (
+	"bytes"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/fatih/structs"
 	"github.com/k-kinzal/progressived/pkg/algorithm"
 	"github.com/k-kinzal/progressived/pkg/formura"
 	"github.com/k-kinzal/progressived/pkg/metrics"
+	"github.com/k-kinzal/progressived/pkg/progressived"
 	"github.com/k-kinzal/progressived/pkg/provider"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
 	"os"
 	"strings"
+	"text/template"
 	"time"
 )
 
 var (
 	config Config
+
+	providerTypes = []string{
+		provider.Route53ProviderType,
+		provider.GatewayAPIProviderType,
+		provider.IstioProviderType,
+		provider.NginxIngressProviderType,
+		provider.ReplicaRatioProviderType,
+		provider.ConsulProviderType,
+		provider.HAProxyProviderType,
+		provider.TraefikProviderType,
+		provider.NginxProviderType,
+		provider.AppMeshProviderType,
+		provider.VPCLatticeProviderType,
+		provider.CloudFrontProviderType,
+	}
+
+	metricsTypes = []string{
+		metrics.CloudWatchMetricsType,
+		metrics.CloudWatchAlarmMetricsType,
+		metrics.DatadogMetricsType,
+		metrics.CloudWatchLogsMetricsType,
+		metrics.ElasticsearchMetricsType,
+		metrics.HTTPMetricsType,
+		metrics.ExecMetricsType,
+		metrics.SQLMetricsType,
+		metrics.AccessLogMetricsType,
+		metrics.PushMetricsType,
+		metrics.ProbeMetricsType,
+		metrics.Route53HealthCheckMetricsType,
+	}
 )
 
 type Route53ProviderConfig struct {
@@ -22,15 +60,264 @@ type Route53ProviderConfig struct {
 	RecordName            string `yaml:"recordName"`
 	SourceIdentifier      string `yaml:"sourceIdentifier"`
 	DestinationIdentifier string `yaml:"destinationIdentifier"`
+	TotalWeight           int64  `yaml:"totalWeight"`
+}
+
+type KubernetesProviderConfig struct {
+	Kubeconfig string `yaml:"kubeconfig"`
+	Context    string `yaml:"context"`
+	Namespace  string `yaml:"namespace"`
+}
+
+type GatewayAPIProviderConfig struct {
+	Version            string `yaml:"version"`
+	RouteName          string `yaml:"routeName"`
+	RuleName           string `yaml:"ruleName"`
+	SourceService      string `yaml:"sourceService"`
+	DestinationService string `yaml:"destinationService"`
+	TotalWeight        int64  `yaml:"totalWeight"`
+}
+
+type IstioProviderConfig struct {
+	Version            string `yaml:"version"`
+	VirtualServiceName string `yaml:"virtualServiceName"`
+	RouteName          string `yaml:"routeName"`
+	SourceHost         string `yaml:"sourceHost"`
+	SourceSubset       string `yaml:"sourceSubset"`
+	DestinationHost    string `yaml:"destinationHost"`
+	DestinationSubset  string `yaml:"destinationSubset"`
+}
+
+type NginxIngressProviderConfig struct {
+	PrimaryIngress string `yaml:"primaryIngress"`
+	CanaryIngress  string `yaml:"canaryIngress"`
+	CanaryService  string `yaml:"canaryService"`
+	Completion     string `yaml:"completion"`
+	TotalWeight    int64  `yaml:"totalWeight"`
+}
+
+type ReplicaRatioProviderConfig struct {
+	SourceDeployment      string `yaml:"sourceDeployment"`
+	DestinationDeployment string `yaml:"destinationDeployment"`
+	TotalReplicas         int32  `yaml:"totalReplicas"`
+}
+
+type ConsulProviderConfig struct {
+	Address           string `yaml:"address"`
+	Datacenter        string `yaml:"datacenter"`
+	Namespace         string `yaml:"namespace"`
+	ServiceName       string `yaml:"serviceName"`
+	SourceSubset      string `yaml:"sourceSubset"`
+	DestinationSubset string `yaml:"destinationSubset"`
+}
+
+type HAProxyProviderConfig struct {
+	Address            string        `yaml:"address"`
+	Backend            string        `yaml:"backend"`
+	SourceServers      []string      `yaml:"sourceServers"`
+	DestinationServers []string      `yaml:"destinationServers"`
+	ConfigFile         string        `yaml:"configFile"`
+	TotalWeight        int64         `yaml:"totalWeight"`
+	Timeout            time.Duration `yaml:"timeout"`
+}
+
+type TraefikProviderConfig struct {
+	File               string        `yaml:"file"`
+	Format             string        `yaml:"format"`
+	ServiceName        string        `yaml:"serviceName"`
+	SourceService      string        `yaml:"sourceService"`
+	DestinationService string        `yaml:"destinationService"`
+	APIURL             string        `yaml:"apiURL"`
+	VerifyTimeout      time.Duration `yaml:"verifyTimeout"`
+	TotalWeight        int64         `yaml:"totalWeight"`
+}
+
+type NginxProviderConfig struct {
+	TemplateFile       string        `yaml:"templateFile"`
+	File               string        `yaml:"file"`
+	Upstream           string        `yaml:"upstream"`
+	SourceServers      []string      `yaml:"sourceServers"`
+	DestinationServers []string      `yaml:"destinationServers"`
+	TestCommand        string        `yaml:"testCommand"`
+	ReloadCommand      string        `yaml:"reloadCommand"`
+	PIDFile            string        `yaml:"pidFile"`
+	TotalWeight        int64         `yaml:"totalWeight"`
+	Timeout            time.Duration `yaml:"timeout"`
+}
+
+type AppMeshProviderConfig struct {
+	MeshName               string `yaml:"meshName"`
+	MeshOwner              string `yaml:"meshOwner"`
+	VirtualRouterName      string `yaml:"virtualRouterName"`
+	RouteName              string `yaml:"routeName"`
+	SourceVirtualNode      string `yaml:"sourceVirtualNode"`
+	DestinationVirtualNode string `yaml:"destinationVirtualNode"`
+	TotalWeight            int64  `yaml:"totalWeight"`
+}
+
+type VPCLatticeProviderConfig struct {
+	ServiceIdentifier      string `yaml:"serviceIdentifier"`
+	ListenerIdentifier     string `yaml:"listenerIdentifier"`
+	RuleIdentifier         string `yaml:"ruleIdentifier"`
+	SourceTargetGroup      string `yaml:"sourceTargetGroup"`
+	DestinationTargetGroup string `yaml:"destinationTargetGroup"`
+	TotalWeight            int64  `yaml:"totalWeight"`
+}
+
+type CloudFrontProviderConfig struct {
+	PrimaryDistributionId        string  `yaml:"primaryDistributionId"`
+	StagingDistributionId        string  `yaml:"stagingDistributionId"`
+	ContinuousDeploymentPolicyId string  `yaml:"continuousDeploymentPolicyId"`
+	MaxStagingWeight             float64 `yaml:"maxStagingWeight"`
 }
 
 type ProviderConfig struct {
 	Type string `yaml:"type"`
 
-	Route53Provider Route53ProviderConfig `yaml:"route53"`
+	Route53Provider    Route53ProviderConfig      `yaml:"route53"`
+	Kubernetes         KubernetesProviderConfig   `yaml:"kubernetes"`
+	GatewayAPIProvider GatewayAPIProviderConfig   `yaml:"gatewayAPI"`
+	IstioProvider      IstioProviderConfig        `yaml:"istio"`
+	NginxIngress       NginxIngressProviderConfig `yaml:"nginxIngress"`
+	ReplicaRatio       ReplicaRatioProviderConfig `yaml:"replicaRatio"`
+	Consul             ConsulProviderConfig       `yaml:"consul"`
+	HAProxy            HAProxyProviderConfig      `yaml:"haproxy"`
+	Traefik            TraefikProviderConfig      `yaml:"traefik"`
+	Nginx              NginxProviderConfig        `yaml:"nginx"`
+	AppMesh            AppMeshProviderConfig      `yaml:"appMesh"`
+	VPCLattice         VPCLatticeProviderConfig   `yaml:"vpcLattice"`
+	CloudFront         CloudFrontProviderConfig   `yaml:"cloudFront"`
+}
+
+type CloudWatchQueryConfig struct {
+	Id         string            `yaml:"id"`
+	Label      string            `yaml:"label"`
+	Namespace  string            `yaml:"namespace"`
+	MetricName string            `yaml:"metricName"`
+	Dimensions map[string]string `yaml:"dimensions"`
+	Stat       string            `yaml:"stat"`
+	Unit       string            `yaml:"unit"`
+	Period     time.Duration     `yaml:"period"`
+	Expression string            `yaml:"expression"`
+	ReturnData *bool             `yaml:"returnData"`
+}
+
+// CloudWatchPresetConfig is a preset defined in the config file. The strings in the
+// queries are templates of the parameters, e.g. `{{.functionName}}`.
+type CloudWatchPresetConfig struct {
+	Description string                  `yaml:"description"`
+	Parameters  []string                `yaml:"parameters"`
+	Condition   string                  `yaml:"condition"`
+	Queries     []CloudWatchQueryConfig `yaml:"queries"`
 }
 
 type CloudWatchMetricsConfig struct {
+	ResultId         string                            `yaml:"resultId"`
+	Queries          []CloudWatchQueryConfig           `yaml:"queries"`
+	Preset           string                            `yaml:"preset"`
+	PresetParameters map[string]string                 `yaml:"presetParameters"`
+	Presets          map[string]CloudWatchPresetConfig `yaml:"presets"`
+	AlarmNames       []string                          `yaml:"alarmNames"`
+	AlarmNamePrefix  string                            `yaml:"alarmNamePrefix"`
+}
+
+type CloudWatchLogsMetricsConfig struct {
+	LogGroupNames []string      `yaml:"logGroupNames"`
+	Field         string        `yaml:"field"`
+	PollInterval  time.Duration `yaml:"pollInterval"`
+	Timeout       time.Duration `yaml:"timeout"`
+}
+
+type DatadogMetricsConfig struct {
+	Site           string `yaml:"site"`
+	BaseURL        string `yaml:"baseURL"`
+	APIKey         string `yaml:"apiKey"`
+	ApplicationKey string `yaml:"applicationKey"`
+}
+
+type TLSConfig struct {
+	CAFile             string `yaml:"caFile"`
+	CertFile           string `yaml:"certFile"`
+	KeyFile            string `yaml:"keyFile"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+}
+
+type ElasticsearchMetricsConfig struct {
+	URL       string        `yaml:"url"`
+	Index     string        `yaml:"index"`
+	ValuePath string        `yaml:"valuePath"`
+	Username  string        `yaml:"username"`
+	Password  string        `yaml:"password"`
+	APIKey    string        `yaml:"apiKey"`
+	TLS       TLSConfig     `yaml:"tls"`
+	Timeout   time.Duration `yaml:"timeout"`
+}
+
+type HTTPMetricsConfig struct {
+	ValuePath         string        `yaml:"valuePath"`
+	StatusCodes       []int         `yaml:"statusCodes"`
+	NoDataStatusCodes []int         `yaml:"noDataStatusCodes"`
+	Retries           int           `yaml:"retries"`
+	RetryInterval     time.Duration `yaml:"retryInterval"`
+	TLS               TLSConfig     `yaml:"tls"`
+	Timeout           time.Duration `yaml:"timeout"`
+}
+
+type ExecMetricsConfig struct {
+	Command        string        `yaml:"command"`
+	Args           []string      `yaml:"args"`
+	QueryMode      string        `yaml:"queryMode"`
+	QueryEnv       string        `yaml:"queryEnv"`
+	NoDataExitCode int           `yaml:"noDataExitCode"`
+	Timeout        time.Duration `yaml:"timeout"`
+}
+
+type SQLMetricsConfig struct {
+	Driver  string        `yaml:"driver"`
+	DSN     string        `yaml:"dsn"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type AccessLogFieldsConfig struct {
+	Time     string `yaml:"time"`
+	Status   string `yaml:"status"`
+	Latency  string `yaml:"latency"`
+	Upstream string `yaml:"upstream"`
+}
+
+type AccessLogMetricsConfig struct {
+	Paths       []string              `yaml:"paths"`
+	Format      string                `yaml:"format"`
+	Fields      AccessLogFieldsConfig `yaml:"fields"`
+	Upstream    string                `yaml:"upstream"`
+	ErrorStatus int                   `yaml:"errorStatus"`
+}
+
+type PushMetricsConfig struct {
+	OTLPHTTPAddress string        `yaml:"otlpHTTPAddress"`
+	OTLPGRPCAddress string        `yaml:"otlpGRPCAddress"`
+	StatsDAddress   string        `yaml:"statsdAddress"`
+	Window          time.Duration `yaml:"window"`
+}
+
+// ProbeMetricsConfig configures the synthetic probes. The update and rollback commands evaluate only the first round of
+// the probes, so probing over the metrics period needs the run command.
+type ProbeMetricsConfig struct {
+	Type        string            `yaml:"type"`
+	Source      string            `yaml:"source"`
+	Destination string            `yaml:"destination"`
+	Method      string            `yaml:"method"`
+	Headers     map[string]string `yaml:"headers"`
+	StatusCodes []int             `yaml:"statusCodes"`
+	DNSServer   string            `yaml:"dnsServer"`
+	Interval    time.Duration     `yaml:"interval"`
+	Timeout     time.Duration     `yaml:"timeout"`
+	TLS         TLSConfig         `yaml:"tls"`
+}
+
+type Route53HealthCheckMetricsConfig struct {
+	HealthCheckId string `yaml:"healthCheckId"`
+	RecordType    string `yaml:"recordType"`
 }
 
 type MetricsConfig struct {
@@ -39,8 +326,21 @@ type MetricsConfig struct {
 	Query       string        `yaml:"query"`
 	AllowNoData bool          `yaml:"allowNoData"`
 	Condition   string        `yaml:"condition"`
+	Reduction   string        `yaml:"reduction"`
+
+	RequireAllDatapoints bool `yaml:"requireAllDatapoints"`
 
-	CloudWatchMetricsConfig CloudWatchMetricsConfig `yaml:"cloudwatch"`
+	CloudWatchMetricsConfig         CloudWatchMetricsConfig         `yaml:"cloudwatch"`
+	CloudWatchLogsMetricsConfig     CloudWatchLogsMetricsConfig     `yaml:"cloudwatchLogs"`
+	DatadogMetricsConfig            DatadogMetricsConfig            `yaml:"datadog"`
+	ElasticsearchMetricsConfig      ElasticsearchMetricsConfig      `yaml:"elasticsearch"`
+	HTTPMetricsConfig               HTTPMetricsConfig               `yaml:"http"`
+	ExecMetricsConfig               ExecMetricsConfig               `yaml:"exec"`
+	SQLMetricsConfig                SQLMetricsConfig                `yaml:"sql"`
+	AccessLogMetricsConfig          AccessLogMetricsConfig          `yaml:"accesslog"`
+	PushMetricsConfig               PushMetricsConfig               `yaml:"push"`
+	ProbeMetricsConfig              ProbeMetricsConfig              `yaml:"probe"`
+	Route53HealthCheckMetricsConfig Route53HealthCheckMetricsConfig `yaml:"route53HealthCheck"`
 }
 
 type AlgorithmConfig struct {
@@ -51,7 +351,7 @@ type AlgorithmConfig struct {
 type Config struct {
 	Provider  ProviderConfig  `yaml:"provider"`
 	Metrics   MetricsConfig   `yaml:"metrics"`
-	Algorithm AlgorithmConfig `yamk:"algorithm"`
+	Algorithm AlgorithmConfig `yaml:"algorithm"`
 }
 
 func setFlags(cmd *cobra.Command) *cobra.Command {
@@ -60,17 +360,169 @@ func setFlags(cmd *cobra.Command) *cobra.Command {
 	cmd.Flags().StringVar(&config.Provider.Route53Provider.RecordName, "route53-record-name", "", "Record Name for AWS Route53")
 	cmd.Flags().StringVar(&config.Provider.Route53Provider.SourceIdentifier, "route53-source-identifier", "", "Identifier of the AWS Route53 migration source")
 	cmd.Flags().StringVar(&config.Provider.Route53Provider.DestinationIdentifier, "route53-destination-identifier", "", "Identifier of the Route53 migration destination")
+	cmd.Flags().Int64Var(&config.Provider.Route53Provider.TotalWeight, "route53-total-weight", provider.Route53DefaultTotalWeight, fmt.Sprintf("Sum of the source and destination weights for AWS Route53 (up to %d)", provider.Route53MaxWeight))
+	cmd.Flags().StringVar(&config.Provider.Kubernetes.Kubeconfig, "kubeconfig", "", "Path to the kubeconfig file (defaults to the in-cluster config or the default kubeconfig)")
+	cmd.Flags().StringVar(&config.Provider.Kubernetes.Context, "kube-context", "", "Context in the kubeconfig")
+	cmd.Flags().StringVar(&config.Provider.Kubernetes.Namespace, "kube-namespace", "default", "Namespace of the Kubernetes resources")
+	cmd.Flags().StringVar(&config.Provider.GatewayAPIProvider.Version, "gateway-api-version", provider.GatewayAPIDefaultVersion, "Version of the Gateway API (e.g. v1, v1beta1)")
+	cmd.Flags().StringVar(&config.Provider.GatewayAPIProvider.RouteName, "gateway-api-route-name", "", "Name of the HTTPRoute")
+	cmd.Flags().StringVar(&config.Provider.GatewayAPIProvider.RuleName, "gateway-api-rule-name", "", "Name of the rule in the HTTPRoute (defaults to the first rule with both services)")
+	cmd.Flags().StringVar(&config.Provider.GatewayAPIProvider.SourceService, "gateway-api-source-service", "", "Service name of the backendRef of the migration source")
+	cmd.Flags().StringVar(&config.Provider.GatewayAPIProvider.DestinationService, "gateway-api-destination-service", "", "Service name of the backendRef of the migration destination")
+	cmd.Flags().Int64Var(&config.Provider.GatewayAPIProvider.TotalWeight, "gateway-api-total-weight", provider.GatewayAPIDefaultTotalWeight, fmt.Sprintf("Sum of the source and destination weights for the HTTPRoute (up to %d)", provider.GatewayAPIMaxWeight))
+	cmd.Flags().StringVar(&config.Provider.IstioProvider.Version, "istio-version", provider.IstioDefaultVersion, "Version of the Istio networking API (e.g. v1beta1, v1)")
+	cmd.Flags().StringVar(&config.Provider.IstioProvider.VirtualServiceName, "istio-virtual-service-name", "", "Name of the VirtualService")
+	cmd.Flags().StringVar(&config.Provider.IstioProvider.RouteName, "istio-route-name", "", "Name of the HTTP route in the VirtualService (defaults to the first route with both destinations)")
+	cmd.Flags().StringVar(&config.Provider.IstioProvider.SourceHost, "istio-source-host", "", "Host of the destination of the migration source")
+	cmd.Flags().StringVar(&config.Provider.IstioProvider.SourceSubset, "istio-source-subset", "", "Subset of the destination of the migration source")
+	cmd.Flags().StringVar(&config.Provider.IstioProvider.DestinationHost, "istio-destination-host", "", "Host of the destination of the migration destination (defaults to the source host)")
+	cmd.Flags().StringVar(&config.Provider.IstioProvider.DestinationSubset, "istio-destination-subset", "", "Subset of the destination of the migration destination")
+	cmd.Flags().StringVar(&config.Provider.NginxIngress.PrimaryIngress, "nginx-ingress-primary", "", "Name of the primary Ingress of the migration source")
+	cmd.Flags().StringVar(&config.Provider.NginxIngress.CanaryIngress, "nginx-ingress-canary", "", "Name of the canary Ingress of the migration destination (defaults to <primary>-canary)")
+	cmd.Flags().StringVar(&config.Provider.NginxIngress.CanaryService, "nginx-ingress-canary-service", "", "Service of the migration destination to create the canary Ingress from the primary Ingress")
+	cmd.Flags().StringVar(&config.Provider.NginxIngress.Completion, "nginx-ingress-completion", provider.NginxIngressCompletionKeep, fmt.Sprintf("What to do with the canary Ingress at completion (%s, %s, %s)", provider.NginxIngressCompletionKeep, provider.NginxIngressCompletionRemove, provider.NginxIngressCompletionPromote))
+	cmd.Flags().Int64Var(&config.Provider.NginxIngress.TotalWeight, "nginx-ingress-total-weight", provider.NginxIngressDefaultTotalWeight, fmt.Sprintf("Canary weight that routes all traffic to the canary (up to %d)", provider.NginxIngressMaxWeight))
+	cmd.Flags().StringVar(&config.Provider.ReplicaRatio.SourceDeployment, "replica-ratio-source-deployment", "", "Name of the Deployment of the migration source")
+	cmd.Flags().StringVar(&config.Provider.ReplicaRatio.DestinationDeployment, "replica-ratio-destination-deployment", "", "Name of the Deployment of the migration destination")
+	cmd.Flags().Int32Var(&config.Provider.ReplicaRatio.TotalReplicas, "replica-ratio-total-replicas", 0, "Sum of the replicas of the two Deployments (defaults to the current sum)")
+	cmd.Flags().StringVar(&config.Provider.Consul.Address, "consul-address", "", "Address of the Consul HTTP API (defaults to CONSUL_HTTP_ADDR or http://127.0.0.1:8500)")
+	cmd.Flags().StringVar(&config.Provider.Consul.Datacenter, "consul-datacenter", "", "Datacenter of the Consul service-splitter")
+	cmd.Flags().StringVar(&config.Provider.Consul.Namespace, "consul-namespace", "", "Namespace of the Consul service-splitter")
+	cmd.Flags().StringVar(&config.Provider.Consul.ServiceName, "consul-service-name", "", "Name of the Consul service-splitter")
+	cmd.Flags().StringVar(&config.Provider.Consul.SourceSubset, "consul-source-subset", "", "Service subset of the migration source")
+	cmd.Flags().StringVar(&config.Provider.Consul.DestinationSubset, "consul-destination-subset", "", "Service subset of the migration destination")
+	cmd.Flags().StringVar(&config.Provider.HAProxy.Address, "haproxy-address", "", "Address of the HAProxy runtime API (e.g. /var/run/haproxy.sock, 127.0.0.1:9999)")
+	cmd.Flags().StringVar(&config.Provider.HAProxy.Backend, "haproxy-backend", "", "Name of the HAProxy backend")
+	cmd.Flags().StringSliceVar(&config.Provider.HAProxy.SourceServers, "haproxy-source-server", nil, "Name of the server of the migration source in the backend")
+	cmd.Flags().StringSliceVar(&config.Provider.HAProxy.DestinationServers, "haproxy-destination-server", nil, "Name of the server of the migration destination in the backend")
+	cmd.Flags().StringVar(&config.Provider.HAProxy.ConfigFile, "haproxy-config-file", "", "HAProxy config file to persist the weights")
+	cmd.Flags().Int64Var(&config.Provider.HAProxy.TotalWeight, "haproxy-total-weight", provider.HAProxyDefaultTotalWeight, fmt.Sprintf("Sum of the weights of the source and destination servers for HAProxy (up to %d)", provider.HAProxyMaxWeight))
+	cmd.Flags().StringVar(&config.Provider.Traefik.File, "traefik-file", "", "Traefik dynamic configuration file of the weighted service")
+	cmd.Flags().StringVar(&config.Provider.Traefik.Format, "traefik-format", "", fmt.Sprintf("Format of the Traefik dynamic configuration file (%s, %s; defaults to the file extension)", provider.TraefikFormatYAML, provider.TraefikFormatTOML))
+	cmd.Flags().StringVar(&config.Provider.Traefik.ServiceName, "traefik-service", "", "Name of the Traefik weighted service")
+	cmd.Flags().StringVar(&config.Provider.Traefik.SourceService, "traefik-source-service", "", "Service of the migration source in the weighted service")
+	cmd.Flags().StringVar(&config.Provider.Traefik.DestinationService, "traefik-destination-service", "", "Service of the migration destination in the weighted service")
+	cmd.Flags().StringVar(&config.Provider.Traefik.APIURL, "traefik-api-url", "", "URL of the Traefik API to verify that the weights are loaded (e.g. http://127.0.0.1:8080)")
+	cmd.Flags().DurationVar(&config.Provider.Traefik.VerifyTimeout, "traefik-verify-timeout", 30*time.Second, "Time to wait for Traefik to load the weights")
+	cmd.Flags().Int64Var(&config.Provider.Traefik.TotalWeight, "traefik-total-weight", provider.TraefikDefaultTotalWeight, fmt.Sprintf("Sum of the weights of the source and destination services for Traefik (up to %d)", provider.TraefikMaxWeight))
+	cmd.Flags().StringVar(&config.Provider.Nginx.TemplateFile, "nginx-template", "", "Template file of the nginx upstream block (defaults to a template that renders only the upstream block)")
+	cmd.Flags().StringVar(&config.Provider.Nginx.File, "nginx-file", "", "File where the nginx template is rendered")
+	cmd.Flags().StringVar(&config.Provider.Nginx.Upstream, "nginx-upstream", "", "Name of the nginx upstream")
+	cmd.Flags().StringSliceVar(&config.Provider.Nginx.SourceServers, "nginx-source-server", nil, "Address of the server of the migration source in the upstream")
+	cmd.Flags().StringSliceVar(&config.Provider.Nginx.DestinationServers, "nginx-destination-server", nil, "Address of the server of the migration destination in the upstream")
+	cmd.Flags().StringVar(&config.Provider.Nginx.TestCommand, "nginx-test-command", strings.Join(provider.NginxDefaultTestCommand, " "), "Command to validate the rendered nginx configuration")
+	cmd.Flags().StringVar(&config.Provider.Nginx.ReloadCommand, "nginx-reload-command", "", "Command to reload nginx (defaults to sending SIGHUP to the process in --nginx-pid-file)")
+	cmd.Flags().StringVar(&config.Provider.Nginx.PIDFile, "nginx-pid-file", provider.NginxDefaultPIDFile, "PID file of the nginx master process")
+	cmd.Flags().Int64Var(&config.Provider.Nginx.TotalWeight, "nginx-total-weight", provider.NginxDefaultTotalWeight, fmt.Sprintf("Sum of the weights of the source and destination servers for nginx (up to %d)", provider.NginxMaxWeight))
+	cmd.Flags().DurationVar(&config.Provider.Nginx.Timeout, "nginx-timeout", 30*time.Second, "Timeout of the nginx test and reload commands")
+	cmd.Flags().StringVar(&config.Provider.AppMesh.MeshName, "appmesh-mesh-name", "", "Name of the AWS App Mesh mesh")
+	cmd.Flags().StringVar(&config.Provider.AppMesh.MeshOwner, "appmesh-mesh-owner", "", "AWS account ID of the owner of the shared mesh")
+	cmd.Flags().StringVar(&config.Provider.AppMesh.VirtualRouterName, "appmesh-virtual-router", "", "Name of the AWS App Mesh virtual router")
+	cmd.Flags().StringVar(&config.Provider.AppMesh.RouteName, "appmesh-route", "", "Name of the AWS App Mesh route")
+	cmd.Flags().StringVar(&config.Provider.AppMesh.SourceVirtualNode, "appmesh-source-virtual-node", "", "Virtual node of the AWS App Mesh migration source")
+	cmd.Flags().StringVar(&config.Provider.AppMesh.DestinationVirtualNode, "appmesh-destination-virtual-node", "", "Virtual node of the AWS App Mesh migration destination")
+	cmd.Flags().Int64Var(&config.Provider.AppMesh.TotalWeight, "appmesh-total-weight", provider.AppMeshDefaultTotalWeight, fmt.Sprintf("Sum of the source and destination weights for AWS App Mesh (up to %d)", provider.AppMeshMaxWeight))
+	cmd.Flags().StringVar(&config.Provider.VPCLattice.ServiceIdentifier, "vpc-lattice-service", "", "ID or ARN of the Amazon VPC Lattice service")
+	cmd.Flags().StringVar(&config.Provider.VPCLattice.ListenerIdentifier, "vpc-lattice-listener", "", "ID or ARN of the Amazon VPC Lattice listener")
+	cmd.Flags().StringVar(&config.Provider.VPCLattice.RuleIdentifier, "vpc-lattice-rule", "", "ID or ARN of the Amazon VPC Lattice listener rule")
+	cmd.Flags().StringVar(&config.Provider.VPCLattice.SourceTargetGroup, "vpc-lattice-source-target-group", "", "ID or ARN of the target group of the Amazon VPC Lattice migration source")
+	cmd.Flags().StringVar(&config.Provider.VPCLattice.DestinationTargetGroup, "vpc-lattice-destination-target-group", "", "ID or ARN of the target group of the Amazon VPC Lattice migration destination")
+	cmd.Flags().Int64Var(&config.Provider.VPCLattice.TotalWeight, "vpc-lattice-total-weight", provider.VPCLatticeDefaultTotalWeight, fmt.Sprintf("Sum of the source and destination weights for Amazon VPC Lattice (up to %d)", provider.VPCLatticeMaxWeight))
+	cmd.Flags().StringVar(&config.Provider.CloudFront.PrimaryDistributionId, "cloudfront-primary-distribution-id", "", "ID of the primary distribution of Amazon CloudFront")
+	cmd.Flags().StringVar(&config.Provider.CloudFront.StagingDistributionId, "cloudfront-staging-distribution-id", "", "ID of the staging distribution of Amazon CloudFront")
+	cmd.Flags().StringVar(&config.Provider.CloudFront.ContinuousDeploymentPolicyId, "cloudfront-continuous-deployment-policy-id", "", "ID of the continuous deployment policy (defaults to the policy attached to the primary distribution)")
+	cmd.Flags().Float64Var(&config.Provider.CloudFront.MaxStagingWeight, "cloudfront-max-staging-weight", provider.CloudFrontMaxStagingWeight, fmt.Sprintf("Largest percentage routed to the staging distribution, above which it is promoted (up to %g)", provider.CloudFrontMaxStagingWeight))
 	cmd.Flags().StringVar(&config.Metrics.Type, "metrics-type", metrics.CloudWatchMetricsType, "Types of metrics to collect")
 	cmd.Flags().DurationVar(&config.Metrics.Period, "metrics-period", 5*time.Minute, "Collection period for metrics")
 	cmd.Flags().StringVar(&config.Metrics.Query, "query", "", "A query to collect metrics")
 	cmd.Flags().BoolVar(&config.Metrics.AllowNoData, "allow-no-data", false, "If true, allow the collection of metrics to no data")
 	cmd.Flags().StringVar(&config.Metrics.Condition, "condition", "", "Rollback if the collected metrics do not match the conditions")
+	cmd.Flags().StringVar(&config.Metrics.Reduction, "metrics-reduction", metrics.ReductionLatest, "Reduction of the collected datapoints to a single value (latest, average, min, max, sum or a percentile such as p99)")
+	cmd.Flags().BoolVar(&config.Metrics.RequireAllDatapoints, "require-all-datapoints", false, "If true, all collected datapoints must match the conditions")
+	cmd.Flags().StringVar(&config.Metrics.CloudWatchMetricsConfig.ResultId, "cloudwatch-result-id", "", "Id of the query whose result is used (default the first query that returns data)")
+	cmd.Flags().StringVar(&config.Metrics.CloudWatchMetricsConfig.Preset, "cloudwatch-preset", "", fmt.Sprintf("Name of the CloudWatch query preset (%s)", strings.Join(metrics.CloudWatchPresetNames(), ", ")))
+	cmd.Flags().StringToStringVar(&config.Metrics.CloudWatchMetricsConfig.PresetParameters, "cloudwatch-preset-parameter", nil, "Parameters of the CloudWatch query preset (e.g. targetGroup=arn:aws:elasticloadbalancing:...)")
+	cmd.Flags().StringSliceVar(&config.Metrics.CloudWatchMetricsConfig.AlarmNames, "cloudwatch-alarm-name", nil, "Name of the CloudWatch alarm that rolls back when it is in ALARM state")
+	cmd.Flags().StringVar(&config.Metrics.CloudWatchMetricsConfig.AlarmNamePrefix, "cloudwatch-alarm-prefix", "", "Prefix of the CloudWatch alarms that roll back when they are in ALARM state")
+	cmd.Flags().StringSliceVar(&config.Metrics.CloudWatchLogsMetricsConfig.LogGroupNames, "cloudwatch-logs-group-name", nil, "Name of the log group to run the CloudWatch Logs Insights query")
+	cmd.Flags().StringVar(&config.Metrics.CloudWatchLogsMetricsConfig.Field, "cloudwatch-logs-field", "", "Field of the first result row of the CloudWatch Logs Insights query (default the first field)")
+	cmd.Flags().DurationVar(&config.Metrics.CloudWatchLogsMetricsConfig.Timeout, "cloudwatch-logs-timeout", time.Minute, "Time to wait for the CloudWatch Logs Insights query to complete")
+	cmd.Flags().StringVar(&config.Metrics.DatadogMetricsConfig.Site, "datadog-site", "", fmt.Sprintf("Datadog site (default $DD_SITE or \"%s\")", metrics.DatadogDefaultSite))
+	cmd.Flags().StringVar(&config.Metrics.ElasticsearchMetricsConfig.URL, "elasticsearch-url", "", "URL of the Elasticsearch or OpenSearch cluster")
+	cmd.Flags().StringVar(&config.Metrics.ElasticsearchMetricsConfig.Index, "elasticsearch-index", "", "Index to search in Elasticsearch or OpenSearch")
+	cmd.Flags().StringVar(&config.Metrics.ElasticsearchMetricsConfig.ValuePath, "elasticsearch-value-path", "", "Path of the value in the search response (e.g. aggregations.error_rate.value)")
+	cmd.Flags().StringVar(&config.Metrics.HTTPMetricsConfig.ValuePath, "http-value-path", "", "JSONPath of the value in the HTTP response (e.g. $.health.score)")
+	cmd.Flags().IntVar(&config.Metrics.HTTPMetricsConfig.Retries, "http-retries", 2, "Number of retries of the HTTP request on network errors, 429 and 5xx")
+	cmd.Flags().DurationVar(&config.Metrics.HTTPMetricsConfig.Timeout, "http-timeout", 30*time.Second, "Timeout of the HTTP request")
+	cmd.Flags().StringVar(&config.Metrics.ExecMetricsConfig.Command, "exec-command", "", "Command that prints the metrics value to the standard output")
+	cmd.Flags().StringArrayVar(&config.Metrics.ExecMetricsConfig.Args, "exec-arg", nil, "Argument of the command, can be specified multiple times")
+	cmd.Flags().StringVar(&config.Metrics.ExecMetricsConfig.QueryMode, "exec-query-mode", metrics.ExecQueryArgument, fmt.Sprintf("How the query is passed to the command (%s, %s, %s)", metrics.ExecQueryArgument, metrics.ExecQueryStdin, metrics.ExecQueryEnvironment))
+	cmd.Flags().IntVar(&config.Metrics.ExecMetricsConfig.NoDataExitCode, "exec-no-data-exit-code", 0, "Exit code of the command that means there was no data (0 disables it)")
+	cmd.Flags().DurationVar(&config.Metrics.ExecMetricsConfig.Timeout, "exec-timeout", 30*time.Second, "Timeout of the command")
+	cmd.Flags().StringVar(&config.Metrics.SQLMetricsConfig.Driver, "sql-driver", "", fmt.Sprintf("Driver of the SQL database (%s)", strings.Join(sql.Drivers(), ", ")))
+	cmd.Flags().StringVar(&config.Metrics.SQLMetricsConfig.DSN, "sql-dsn", "", "Data source name of the SQL database (default $PROGRESSIVED_SQL_DSN)")
+	cmd.Flags().DurationVar(&config.Metrics.SQLMetricsConfig.Timeout, "sql-timeout", 30*time.Second, "Timeout of the SQL query")
+	cmd.Flags().StringSliceVar(&config.Metrics.AccessLogMetricsConfig.Paths, "accesslog-path", nil, "Path or glob pattern of the access log files")
+	cmd.Flags().StringVar(&config.Metrics.AccessLogMetricsConfig.Format, "accesslog-format", metrics.AccessLogFormatCombined, fmt.Sprintf("Format of the access logs (%s, %s, %s)", metrics.AccessLogFormatCommon, metrics.AccessLogFormatCombined, metrics.AccessLogFormatJSON))
+	cmd.Flags().StringVar(&config.Metrics.AccessLogMetricsConfig.Upstream, "accesslog-upstream", "", "Upstream address to filter the access logs (e.g. the address of the destination)")
+	cmd.Flags().StringVar(&config.Metrics.PushMetricsConfig.OTLPHTTPAddress, "push-otlp-http-address", "", "Address to receive OTLP metrics over HTTP (e.g. :4318)")
+	cmd.Flags().StringVar(&config.Metrics.PushMetricsConfig.OTLPGRPCAddress, "push-otlp-grpc-address", "", "Address to receive OTLP metrics over gRPC (e.g. :4317)")
+	cmd.Flags().StringVar(&config.Metrics.PushMetricsConfig.StatsDAddress, "push-statsd-address", "", "UDP address to receive StatsD metrics (e.g. :8125)")
+	cmd.Flags().DurationVar(&config.Metrics.PushMetricsConfig.Window, "push-window", 0, "How long the pushed samples are kept (defaults to the metrics period)")
+	cmd.Flags().StringVar(&config.Metrics.ProbeMetricsConfig.Type, "probe-type", metrics.ProbeTypeHTTP, fmt.Sprintf("Type of the probes (%s, %s, %s)", metrics.ProbeTypeHTTP, metrics.ProbeTypeTCP, metrics.ProbeTypeDNS))
+	cmd.Flags().StringVar(&config.Metrics.ProbeMetricsConfig.Source, "probe-source", "", "URL, host:port or host name of the source to probe (queried as \"source:<statistic>\")")
+	cmd.Flags().StringVar(&config.Metrics.ProbeMetricsConfig.Destination, "probe-destination", "", "URL, host:port or host name of the destination to probe (queried as \"destination:<statistic>\")")
+	cmd.Flags().DurationVar(&config.Metrics.ProbeMetricsConfig.Interval, "probe-interval", time.Second, "Interval of the probes to each endpoint (update and rollback probe only once, use run to keep probing)")
+	cmd.Flags().DurationVar(&config.Metrics.ProbeMetricsConfig.Timeout, "probe-timeout", 5*time.Second, "Timeout of each probe")
+	cmd.Flags().StringVar(&config.Metrics.Route53HealthCheckMetricsConfig.HealthCheckId, "route53-health-check-id", "", "ID of the Route53 health check (defaults to the health check of the destination record set)")
+	cmd.Flags().StringVar(&config.Metrics.Route53HealthCheckMetricsConfig.RecordType, "route53-health-check-record-type", "", "Type of the destination record set whose health check is used (e.g. A)")
 	cmd.Flags().StringVar(&config.Algorithm.Type, "algorithm", algorithm.IncreaseAlgorithm, "Algorithm for determining the value to be updated")
 	cmd.Flags().Float64Var(&config.Algorithm.Value, "value", 10, "Reference value to be applied to the algorithm")
 
 	return cmd
 }
 
+// loadConfig reads the YAML configuration file into config.
+// Flags specified on the command line take precedence over the file.
+func loadConfig(cmd *cobra.Command, filename string) error {
+	if filename == "" {
+		return nil
+	}
+
+	var restores []func() error
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		// setting a slice again appends to it, so that it is replaced instead
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			values := append([]string{}, sv.GetSlice()...)
+			restores = append(restores, func() error { return sv.Replace(values) })
+			return
+		}
+		value := f.Value.String()
+		// maps are formatted as `[k=v,...]`, but are set without brackets.
+		// the keys are merged into the map of the config file, and the flag wins for the same key
+		if f.Value.Type() == "stringToString" {
+			value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+		}
+		restores = append(restores, func() error { return f.Value.Set(value) })
+	})
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(b, &config); err != nil {
+		return fmt.Errorf("failed to parse config file `%s`: %w", filename, err)
+	}
+
+	for _, restore := range restores {
+		if err := restore(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func newProvider(config Config) (provider.Provider, error) {
 	var prov provider.Provider
 	switch config.Provider.Type {
@@ -94,35 +546,765 @@ func newProvider(config Config) (provider.Provider, error) {
 			RecordName:            config.Provider.Route53Provider.RecordName,
 			SourceIdentifier:      config.Provider.Route53Provider.SourceIdentifier,
 			DestinationIdentifier: config.Provider.Route53Provider.DestinationIdentifier,
+			TotalWeight:           config.Provider.Route53Provider.TotalWeight,
 		}
 		p, err := provider.NewRoute53Provider(config)
 		if err != nil {
 			return nil, err
 		}
 		prov = p
+	case provider.GatewayAPIProviderType:
+		gwConfig := config.Provider.GatewayAPIProvider
+		if gwConfig.RouteName == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --gateway-api-route-name is required", provider.GatewayAPIProviderType)
+		}
+		if gwConfig.SourceService == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --gateway-api-source-service is required", provider.GatewayAPIProviderType)
+		}
+		if gwConfig.DestinationService == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --gateway-api-destination-service is required", provider.GatewayAPIProviderType)
+		}
+
+		config := &provider.GatewayAPIConfig{
+			Kubernetes:         newKubernetesConfig(config),
+			Version:            gwConfig.Version,
+			Namespace:          config.Provider.Kubernetes.Namespace,
+			RouteName:          gwConfig.RouteName,
+			RuleName:           gwConfig.RuleName,
+			SourceService:      gwConfig.SourceService,
+			DestinationService: gwConfig.DestinationService,
+			TotalWeight:        gwConfig.TotalWeight,
+		}
+		p, err := provider.NewGatewayAPIProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		prov = p
+	case provider.IstioProviderType:
+		istioConfig := config.Provider.IstioProvider
+		if istioConfig.VirtualServiceName == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --istio-virtual-service-name is required", provider.IstioProviderType)
+		}
+		if istioConfig.SourceHost == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --istio-source-host is required", provider.IstioProviderType)
+		}
+		destinationHost := istioConfig.DestinationHost
+		if destinationHost == "" {
+			destinationHost = istioConfig.SourceHost
+		}
+
+		config := &provider.IstioConfig{
+			Kubernetes:         newKubernetesConfig(config),
+			Version:            istioConfig.Version,
+			Namespace:          config.Provider.Kubernetes.Namespace,
+			VirtualServiceName: istioConfig.VirtualServiceName,
+			RouteName:          istioConfig.RouteName,
+			Source:             provider.IstioDestination{Host: istioConfig.SourceHost, Subset: istioConfig.SourceSubset},
+			Destination:        provider.IstioDestination{Host: destinationHost, Subset: istioConfig.DestinationSubset},
+		}
+		p, err := provider.NewIstioProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		prov = p
+	case provider.NginxIngressProviderType:
+		nginxConfig := config.Provider.NginxIngress
+		if nginxConfig.PrimaryIngress == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --nginx-ingress-primary is required", provider.NginxIngressProviderType)
+		}
+
+		config := &provider.NginxIngressConfig{
+			Kubernetes:     newKubernetesConfig(config),
+			Namespace:      config.Provider.Kubernetes.Namespace,
+			PrimaryIngress: nginxConfig.PrimaryIngress,
+			CanaryIngress:  nginxConfig.CanaryIngress,
+			CanaryService:  nginxConfig.CanaryService,
+			Completion:     nginxConfig.Completion,
+			TotalWeight:    nginxConfig.TotalWeight,
+		}
+		p, err := provider.NewNginxIngressProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		prov = p
+	case provider.ReplicaRatioProviderType:
+		rrConfig := config.Provider.ReplicaRatio
+		if rrConfig.SourceDeployment == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --replica-ratio-source-deployment is required", provider.ReplicaRatioProviderType)
+		}
+		if rrConfig.DestinationDeployment == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --replica-ratio-destination-deployment is required", provider.ReplicaRatioProviderType)
+		}
+
+		config := &provider.ReplicaRatioConfig{
+			Kubernetes:            newKubernetesConfig(config),
+			Namespace:             config.Provider.Kubernetes.Namespace,
+			SourceDeployment:      rrConfig.SourceDeployment,
+			DestinationDeployment: rrConfig.DestinationDeployment,
+			TotalReplicas:         rrConfig.TotalReplicas,
+		}
+		p, err := provider.NewReplicaRatioProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		prov = p
+	case provider.ConsulProviderType:
+		consulConfig := config.Provider.Consul
+		if consulConfig.ServiceName == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --consul-service-name is required", provider.ConsulProviderType)
+		}
+		if consulConfig.DestinationSubset == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --consul-destination-subset is required", provider.ConsulProviderType)
+		}
+
+		config := &provider.ConsulConfig{
+			Address:           consulConfig.Address,
+			Datacenter:        consulConfig.Datacenter,
+			Namespace:         consulConfig.Namespace,
+			ServiceName:       consulConfig.ServiceName,
+			SourceSubset:      consulConfig.SourceSubset,
+			DestinationSubset: consulConfig.DestinationSubset,
+		}
+		p, err := provider.NewConsulProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		prov = p
+	case provider.HAProxyProviderType:
+		haproxyConfig := config.Provider.HAProxy
+		if haproxyConfig.Address == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --haproxy-address is required", provider.HAProxyProviderType)
+		}
+		if haproxyConfig.Backend == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --haproxy-backend is required", provider.HAProxyProviderType)
+		}
+		if len(haproxyConfig.SourceServers) == 0 {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --haproxy-source-server is required", provider.HAProxyProviderType)
+		}
+		if len(haproxyConfig.DestinationServers) == 0 {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --haproxy-destination-server is required", provider.HAProxyProviderType)
+		}
+
+		config := &provider.HAProxyConfig{
+			Address:            haproxyConfig.Address,
+			Backend:            haproxyConfig.Backend,
+			SourceServers:      haproxyConfig.SourceServers,
+			DestinationServers: haproxyConfig.DestinationServers,
+			ConfigFile:         haproxyConfig.ConfigFile,
+			TotalWeight:        haproxyConfig.TotalWeight,
+			Timeout:            haproxyConfig.Timeout,
+		}
+		p, err := provider.NewHAProxyProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		prov = p
+	case provider.TraefikProviderType:
+		traefikConfig := config.Provider.Traefik
+		if traefikConfig.File == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --traefik-file is required", provider.TraefikProviderType)
+		}
+		if traefikConfig.ServiceName == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --traefik-service is required", provider.TraefikProviderType)
+		}
+		if traefikConfig.SourceService == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --traefik-source-service is required", provider.TraefikProviderType)
+		}
+		if traefikConfig.DestinationService == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --traefik-destination-service is required", provider.TraefikProviderType)
+		}
+
+		config := &provider.TraefikConfig{
+			File:               traefikConfig.File,
+			Format:             traefikConfig.Format,
+			ServiceName:        traefikConfig.ServiceName,
+			SourceService:      traefikConfig.SourceService,
+			DestinationService: traefikConfig.DestinationService,
+			APIURL:             traefikConfig.APIURL,
+			VerifyTimeout:      traefikConfig.VerifyTimeout,
+			TotalWeight:        traefikConfig.TotalWeight,
+		}
+		p, err := provider.NewTraefikProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		prov = p
+	case provider.NginxProviderType:
+		nginxConfig := config.Provider.Nginx
+		if nginxConfig.File == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --nginx-file is required", provider.NginxProviderType)
+		}
+		if nginxConfig.Upstream == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --nginx-upstream is required", provider.NginxProviderType)
+		}
+		if len(nginxConfig.SourceServers) == 0 {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --nginx-source-server is required", provider.NginxProviderType)
+		}
+		if len(nginxConfig.DestinationServers) == 0 {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --nginx-destination-server is required", provider.NginxProviderType)
+		}
+
+		config := &provider.NginxConfig{
+			TemplateFile:       nginxConfig.TemplateFile,
+			File:               nginxConfig.File,
+			Upstream:           nginxConfig.Upstream,
+			SourceServers:      nginxConfig.SourceServers,
+			DestinationServers: nginxConfig.DestinationServers,
+			TestCommand:        strings.Fields(nginxConfig.TestCommand),
+			ReloadCommand:      strings.Fields(nginxConfig.ReloadCommand),
+			PIDFile:            nginxConfig.PIDFile,
+			TotalWeight:        nginxConfig.TotalWeight,
+			Timeout:            nginxConfig.Timeout,
+		}
+		p, err := provider.NewNginxProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		prov = p
+	case provider.AppMeshProviderType:
+		appMeshConfig := config.Provider.AppMesh
+		if appMeshConfig.MeshName == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --appmesh-mesh-name is required", provider.AppMeshProviderType)
+		}
+		if appMeshConfig.VirtualRouterName == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --appmesh-virtual-router is required", provider.AppMeshProviderType)
+		}
+		if appMeshConfig.RouteName == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --appmesh-route is required", provider.AppMeshProviderType)
+		}
+		if appMeshConfig.SourceVirtualNode == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --appmesh-source-virtual-node is required", provider.AppMeshProviderType)
+		}
+		if appMeshConfig.DestinationVirtualNode == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --appmesh-destination-virtual-node is required", provider.AppMeshProviderType)
+		}
+
+		config := &provider.AppMeshConfig{
+			Sess:                   awsSession,
+			MeshName:               appMeshConfig.MeshName,
+			MeshOwner:              appMeshConfig.MeshOwner,
+			VirtualRouterName:      appMeshConfig.VirtualRouterName,
+			RouteName:              appMeshConfig.RouteName,
+			SourceVirtualNode:      appMeshConfig.SourceVirtualNode,
+			DestinationVirtualNode: appMeshConfig.DestinationVirtualNode,
+			TotalWeight:            appMeshConfig.TotalWeight,
+		}
+		p, err := provider.NewAppMeshProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		prov = p
+	case provider.VPCLatticeProviderType:
+		latticeConfig := config.Provider.VPCLattice
+		if latticeConfig.ServiceIdentifier == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --vpc-lattice-service is required", provider.VPCLatticeProviderType)
+		}
+		if latticeConfig.ListenerIdentifier == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --vpc-lattice-listener is required", provider.VPCLatticeProviderType)
+		}
+		if latticeConfig.RuleIdentifier == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --vpc-lattice-rule is required", provider.VPCLatticeProviderType)
+		}
+		if latticeConfig.SourceTargetGroup == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --vpc-lattice-source-target-group is required", provider.VPCLatticeProviderType)
+		}
+		if latticeConfig.DestinationTargetGroup == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --vpc-lattice-destination-target-group is required", provider.VPCLatticeProviderType)
+		}
+
+		config := &provider.VPCLatticeConfig{
+			Sess:                   awsSession,
+			ServiceIdentifier:      latticeConfig.ServiceIdentifier,
+			ListenerIdentifier:     latticeConfig.ListenerIdentifier,
+			RuleIdentifier:         latticeConfig.RuleIdentifier,
+			SourceTargetGroup:      latticeConfig.SourceTargetGroup,
+			DestinationTargetGroup: latticeConfig.DestinationTargetGroup,
+			TotalWeight:            latticeConfig.TotalWeight,
+		}
+		p, err := provider.NewVPCLatticeProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		prov = p
+	case provider.CloudFrontProviderType:
+		cloudFrontConfig := config.Provider.CloudFront
+		if cloudFrontConfig.PrimaryDistributionId == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --cloudfront-primary-distribution-id is required", provider.CloudFrontProviderType)
+		}
+		if cloudFrontConfig.StagingDistributionId == "" {
+			return nil, fmt.Errorf("if the provider is \"%s\", the --cloudfront-staging-distribution-id is required", provider.CloudFrontProviderType)
+		}
+
+		config := &provider.CloudFrontConfig{
+			Sess:                         awsSession,
+			PrimaryDistributionId:        cloudFrontConfig.PrimaryDistributionId,
+			StagingDistributionId:        cloudFrontConfig.StagingDistributionId,
+			ContinuousDeploymentPolicyId: cloudFrontConfig.ContinuousDeploymentPolicyId,
+			MaxStagingWeight:             cloudFrontConfig.MaxStagingWeight,
+		}
+		p, err := provider.NewCloudFrontProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		prov = p
 	default:
-		return nil, fmt.Errorf("--provider can be either \"%s\"", provider.Route53ProviderType)
+		return nil, fmt.Errorf("--provider can be either %s", quoteJoin(providerTypes))
 	}
 
 	return prov, nil
 }
 
-func newMetrics(config Config) (metrics.Metrics, error) {
+func newKubernetesConfig(config Config) *provider.KubernetesConfig {
+	return &provider.KubernetesConfig{
+		Kubeconfig: config.Provider.Kubernetes.Kubeconfig,
+		Context:    config.Provider.Kubernetes.Context,
+	}
+}
+
+// newMetrics creates the metrics of the config. oneShot is set by the update and rollback commands
+// that exit after a single evaluation, which rejects the metrics that only receive the samples in
+// the background. The run command keeps them running across the evaluations.
+// newProgressived creates the progressived of the config. See newMetrics for oneShot.
+func newProgressived(config Config, oneShot bool) (*progressived.Progressived, error) {
+	pv, err := newProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := newMetrics(config, oneShot)
+	if err != nil {
+		return nil, err
+	}
+
+	gd, err := newGuard(config)
+	if err != nil {
+		return nil, err
+	}
+
+	qb, err := newQueryBuilder(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ag, err := newAlgorithm(config)
+	if err != nil {
+		return nil, err
+	}
+
+	fm, err := newFomura(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &progressived.Progressived{
+		Provider:    pv,
+		Metrics:     ms,
+		Guard:       gd,
+		Builder:     qb,
+		Algorithm:   ag,
+		Formura:     fm,
+		AllowNoData: config.Metrics.AllowNoData,
+
+		RequireAllDatapoints: config.Metrics.RequireAllDatapoints,
+	}, nil
+}
+
+func newCloudWatchQuery(q CloudWatchQueryConfig, expand func(string) string) *metrics.CloudWatchQuery {
+	var dimensions map[string]string
+	if q.Dimensions != nil {
+		dimensions = make(map[string]string, len(q.Dimensions))
+		for k, v := range q.Dimensions {
+			dimensions[k] = expand(v)
+		}
+	}
+	return &metrics.CloudWatchQuery{
+		Id:         q.Id,
+		Label:      expand(q.Label),
+		Namespace:  expand(q.Namespace),
+		MetricName: expand(q.MetricName),
+		Dimensions: dimensions,
+		Stat:       q.Stat,
+		Unit:       q.Unit,
+		Period:     q.Period,
+		Expression: expand(q.Expression),
+		ReturnData: q.ReturnData,
+	}
+}
+
+// registerCloudWatchPresets registers the presets defined in the config file, so that
+// --cloudwatch-preset can select them like the built-in presets.
+func registerCloudWatchPresets(presets map[string]CloudWatchPresetConfig) error {
+	for name, presetConfig := range presets {
+		if len(presetConfig.Queries) == 0 {
+			return fmt.Errorf("metrics.cloudwatch.presets.%s: queries are required", name)
+		}
+		templates := make(map[string]*template.Template)
+		parse := func(s string) error {
+			if _, ok := templates[s]; ok {
+				return nil
+			}
+			tmpl, err := template.New(name).Option("missingkey=zero").Parse(s)
+			if err != nil {
+				return err
+			}
+			templates[s] = tmpl
+			return nil
+		}
+		for _, q := range presetConfig.Queries {
+			for _, s := range []string{q.Label, q.Namespace, q.MetricName, q.Expression} {
+				if err := parse(s); err != nil {
+					return fmt.Errorf("metrics.cloudwatch.presets.%s: %w", name, err)
+				}
+			}
+			for _, s := range q.Dimensions {
+				if err := parse(s); err != nil {
+					return fmt.Errorf("metrics.cloudwatch.presets.%s: %w", name, err)
+				}
+			}
+		}
+
+		queries := presetConfig.Queries
+		preset := &metrics.CloudWatchPreset{
+			Description: presetConfig.Description,
+			Parameters:  presetConfig.Parameters,
+			Condition:   presetConfig.Condition,
+			Queries: func(params map[string]string) []*metrics.CloudWatchQuery {
+				expand := func(s string) string {
+					var buf bytes.Buffer
+					// the templates only read the parameters, so executing them does not fail
+					templates[s].Execute(&buf, params)
+					return buf.String()
+				}
+				expanded := make([]*metrics.CloudWatchQuery, 0, len(queries))
+				for _, q := range queries {
+					expanded = append(expanded, newCloudWatchQuery(q, expand))
+				}
+				return expanded
+			},
+		}
+		if err := metrics.RegisterCloudWatchPreset(name, preset); err != nil {
+			return fmt.Errorf("metrics.cloudwatch.presets: %w", err)
+		}
+	}
+	return nil
+}
+
+func newMetrics(config Config, oneShot bool) (metrics.Metrics, error) {
+	reduction, err := metrics.ParseReduction(config.Metrics.Reduction)
+	if err != nil {
+		return nil, fmt.Errorf("--metrics-reduction: %w", err)
+	}
+
 	var met metrics.Metrics
 	switch config.Metrics.Type {
 	case metrics.CloudWatchMetricsType:
+		cwConfig := config.Metrics.CloudWatchMetricsConfig
+		n := 0
+		for _, specified := range []bool{config.Metrics.Query != "", len(cwConfig.Queries) > 0, cwConfig.Preset != ""} {
+			if specified {
+				n++
+			}
+		}
+		if n > 1 {
+			return nil, errors.New("only one of --query, --cloudwatch-preset and metrics.cloudwatch.queries can be specified")
+		}
+		queries := make([]*metrics.CloudWatchQuery, 0, len(cwConfig.Queries))
+		if cwConfig.Preset != "" {
+			preset, err := metrics.LookupCloudWatchPreset(cwConfig.Preset)
+			if err != nil {
+				return nil, fmt.Errorf("--cloudwatch-preset: %w", err)
+			}
+			q, err := preset.Expand(cwConfig.PresetParameters)
+			if err != nil {
+				return nil, fmt.Errorf("--cloudwatch-preset: %w", err)
+			}
+			queries = q
+		}
+		for _, q := range cwConfig.Queries {
+			queries = append(queries, newCloudWatchQuery(q, func(s string) string { return s }))
+		}
 		config := &metrics.CloudWatchConfig{
-			Sess:   awsSession,
-			Period: config.Metrics.Period,
+			Sess:      awsSession,
+			Period:    config.Metrics.Period,
+			Reduction: reduction,
+			ResultId:  cwConfig.ResultId,
+			Queries:   queries,
+		}
+		m, err := metrics.NewCloudWatchMetrics(config)
+		if err != nil {
+			return nil, err
+		}
+		met = m
+	case metrics.CloudWatchAlarmMetricsType:
+		cwConfig := config.Metrics.CloudWatchMetricsConfig
+		if len(cwConfig.AlarmNames) == 0 && cwConfig.AlarmNamePrefix == "" && config.Metrics.Query == "" {
+			return nil, fmt.Errorf("if the metrics type is \"%s\", the --cloudwatch-alarm-name or --cloudwatch-alarm-prefix is required", metrics.CloudWatchAlarmMetricsType)
+		}
+		m, err := newCloudWatchAlarmMetrics(config)
+		if err != nil {
+			return nil, err
+		}
+		met = m
+	case metrics.CloudWatchLogsMetricsType:
+		if len(config.Metrics.CloudWatchLogsMetricsConfig.LogGroupNames) == 0 {
+			return nil, fmt.Errorf("if the metrics type is \"%s\", the --cloudwatch-logs-group-name is required", metrics.CloudWatchLogsMetricsType)
 		}
-		met = metrics.NewCloudWatchMetrics(config)
+		config := &metrics.CloudWatchLogsConfig{
+			Sess:          awsSession,
+			LogGroupNames: config.Metrics.CloudWatchLogsMetricsConfig.LogGroupNames,
+			Field:         config.Metrics.CloudWatchLogsMetricsConfig.Field,
+			Period:        config.Metrics.Period,
+			PollInterval:  config.Metrics.CloudWatchLogsMetricsConfig.PollInterval,
+			Timeout:       config.Metrics.CloudWatchLogsMetricsConfig.Timeout,
+		}
+		m, err := metrics.NewCloudWatchLogsMetrics(config)
+		if err != nil {
+			return nil, err
+		}
+		met = m
+	case metrics.DatadogMetricsType:
+		config := &metrics.DatadogConfig{
+			Site:           config.Metrics.DatadogMetricsConfig.Site,
+			BaseURL:        config.Metrics.DatadogMetricsConfig.BaseURL,
+			APIKey:         config.Metrics.DatadogMetricsConfig.APIKey,
+			ApplicationKey: config.Metrics.DatadogMetricsConfig.ApplicationKey,
+			Period:         config.Metrics.Period,
+			Reduction:      reduction,
+		}
+		m, err := metrics.NewDatadogMetrics(config)
+		if err != nil {
+			return nil, err
+		}
+		met = m
+	case metrics.ElasticsearchMetricsType:
+		esConfig := config.Metrics.ElasticsearchMetricsConfig
+		if esConfig.URL == "" {
+			return nil, fmt.Errorf("if the metrics type is \"%s\", the --elasticsearch-url is required", metrics.ElasticsearchMetricsType)
+		}
+		if esConfig.ValuePath == "" {
+			return nil, fmt.Errorf("if the metrics type is \"%s\", the --elasticsearch-value-path is required", metrics.ElasticsearchMetricsType)
+		}
+		config := &metrics.ElasticsearchConfig{
+			URL:       esConfig.URL,
+			Index:     esConfig.Index,
+			ValuePath: esConfig.ValuePath,
+			Username:  esConfig.Username,
+			Password:  esConfig.Password,
+			APIKey:    esConfig.APIKey,
+			TLS:       newTLSConfig(esConfig.TLS),
+			Timeout:   esConfig.Timeout,
+		}
+		m, err := metrics.NewElasticsearchMetrics(config)
+		if err != nil {
+			return nil, err
+		}
+		met = m
+	case metrics.HTTPMetricsType:
+		httpConfig := config.Metrics.HTTPMetricsConfig
+		if httpConfig.ValuePath == "" {
+			return nil, fmt.Errorf("if the metrics type is \"%s\", the --http-value-path is required", metrics.HTTPMetricsType)
+		}
+		config := &metrics.HTTPConfig{
+			ValuePath:         httpConfig.ValuePath,
+			StatusCodes:       httpConfig.StatusCodes,
+			NoDataStatusCodes: httpConfig.NoDataStatusCodes,
+			Retries:           httpConfig.Retries,
+			RetryInterval:     httpConfig.RetryInterval,
+			TLS:               newTLSConfig(httpConfig.TLS),
+			Timeout:           httpConfig.Timeout,
+		}
+		m, err := metrics.NewHTTPMetrics(config)
+		if err != nil {
+			return nil, err
+		}
+		met = m
+	case metrics.ExecMetricsType:
+		execConfig := config.Metrics.ExecMetricsConfig
+		if execConfig.Command == "" {
+			return nil, fmt.Errorf("if the metrics type is \"%s\", the --exec-command is required", metrics.ExecMetricsType)
+		}
+		config := &metrics.ExecConfig{
+			Command:        execConfig.Command,
+			Args:           execConfig.Args,
+			QueryMode:      execConfig.QueryMode,
+			QueryEnv:       execConfig.QueryEnv,
+			NoDataExitCode: execConfig.NoDataExitCode,
+			Timeout:        execConfig.Timeout,
+		}
+		m, err := metrics.NewExecMetrics(config)
+		if err != nil {
+			return nil, err
+		}
+		met = m
+	case metrics.SQLMetricsType:
+		sqlConfig := config.Metrics.SQLMetricsConfig
+		if sqlConfig.DSN == "" {
+			sqlConfig.DSN = os.Getenv("PROGRESSIVED_SQL_DSN")
+		}
+		if sqlConfig.Driver == "" {
+			return nil, fmt.Errorf("if the metrics type is \"%s\", the --sql-driver is required", metrics.SQLMetricsType)
+		}
+		if sqlConfig.DSN == "" {
+			return nil, fmt.Errorf("if the metrics type is \"%s\", the --sql-dsn is required", metrics.SQLMetricsType)
+		}
+		config := &metrics.SQLConfig{
+			Driver:  sqlConfig.Driver,
+			DSN:     sqlConfig.DSN,
+			Timeout: sqlConfig.Timeout,
+		}
+		m, err := metrics.NewSQLMetrics(config)
+		if err != nil {
+			return nil, err
+		}
+		met = m
+	case metrics.AccessLogMetricsType:
+		alConfig := config.Metrics.AccessLogMetricsConfig
+		if len(alConfig.Paths) == 0 {
+			return nil, fmt.Errorf("if the metrics type is \"%s\", the --accesslog-path is required", metrics.AccessLogMetricsType)
+		}
+		config := &metrics.AccessLogConfig{
+			Paths:  alConfig.Paths,
+			Format: alConfig.Format,
+			Fields: metrics.AccessLogFields{
+				Time:     alConfig.Fields.Time,
+				Status:   alConfig.Fields.Status,
+				Latency:  alConfig.Fields.Latency,
+				Upstream: alConfig.Fields.Upstream,
+			},
+			Upstream:    alConfig.Upstream,
+			ErrorStatus: alConfig.ErrorStatus,
+			Period:      config.Metrics.Period,
+		}
+		m, err := metrics.NewAccessLogMetrics(config)
+		if err != nil {
+			return nil, err
+		}
+		met = m
+	case metrics.PushMetricsType:
+		if oneShot {
+			return nil, fmt.Errorf("the metrics type \"%s\" receives the metrics pushed over OTLP and StatsD while the process is running, so it can be used only by the run command", metrics.PushMetricsType)
+		}
+		pushConfig := config.Metrics.PushMetricsConfig
+		if pushConfig.OTLPHTTPAddress == "" && pushConfig.OTLPGRPCAddress == "" && pushConfig.StatsDAddress == "" {
+			return nil, fmt.Errorf("if the metrics type is \"%s\", the --push-otlp-http-address, --push-otlp-grpc-address or --push-statsd-address is required", metrics.PushMetricsType)
+		}
+		window := pushConfig.Window
+		if window == 0 {
+			window = config.Metrics.Period
+		}
+		config := &metrics.PushConfig{
+			OTLPHTTPAddress: pushConfig.OTLPHTTPAddress,
+			OTLPGRPCAddress: pushConfig.OTLPGRPCAddress,
+			StatsDAddress:   pushConfig.StatsDAddress,
+			Window:          window,
+			Reduction:       reduction,
+		}
+		m, err := metrics.NewPushMetrics(config)
+		if err != nil {
+			return nil, err
+		}
+		met = m
+	case metrics.ProbeMetricsType:
+		probeConfig := config.Metrics.ProbeMetricsConfig
+		if probeConfig.Destination == "" {
+			return nil, fmt.Errorf("if the metrics type is \"%s\", the --probe-destination is required", metrics.ProbeMetricsType)
+		}
+		endpoints := []struct {
+			name    string
+			address string
+		}{
+			{"source", probeConfig.Source},
+			{"destination", probeConfig.Destination},
+		}
+		var targets []*metrics.ProbeTarget
+		for _, e := range endpoints {
+			if e.address == "" {
+				continue
+			}
+			targets = append(targets, &metrics.ProbeTarget{
+				Name:        e.name,
+				Type:        probeConfig.Type,
+				Address:     e.address,
+				Method:      probeConfig.Method,
+				Headers:     probeConfig.Headers,
+				StatusCodes: probeConfig.StatusCodes,
+				DNSServer:   probeConfig.DNSServer,
+			})
+		}
+		config := &metrics.ProbeConfig{
+			Targets:  targets,
+			Interval: probeConfig.Interval,
+			Timeout:  probeConfig.Timeout,
+			Period:   config.Metrics.Period,
+			TLS:      newTLSConfig(probeConfig.TLS),
+		}
+		m, err := metrics.NewProbeMetrics(config)
+		if err != nil {
+			return nil, err
+		}
+		met = m
+	case metrics.Route53HealthCheckMetricsType:
+		hcConfig := config.Metrics.Route53HealthCheckMetricsConfig
+		r53Config := config.Provider.Route53Provider
+		if hcConfig.HealthCheckId == "" && config.Provider.Type != provider.Route53ProviderType {
+			return nil, fmt.Errorf("if the metrics type is \"%s\" and the provider is not \"%s\", the --route53-health-check-id is required", metrics.Route53HealthCheckMetricsType, provider.Route53ProviderType)
+		}
+		config := &metrics.Route53HealthCheckConfig{
+			Sess:          awsSession,
+			HealthCheckId: hcConfig.HealthCheckId,
+			HostedZoneId:  r53Config.HostedZoneId,
+			RecordName:    r53Config.RecordName,
+			SetIdentifier: r53Config.DestinationIdentifier,
+			Type:          hcConfig.RecordType,
+		}
+		m, err := metrics.NewRoute53HealthCheckMetrics(config)
+		if err != nil {
+			return nil, err
+		}
+		met = m
 	default:
-		return nil, fmt.Errorf("--metrics-type can be either \"%s\"", metrics.CloudWatchMetricsType)
+		return nil, fmt.Errorf("--metrics-type can be either %s", quoteJoin(metricsTypes))
 	}
 
 	return met, nil
 }
 
+func newTLSConfig(config TLSConfig) *metrics.TLSConfig {
+	return &metrics.TLSConfig{
+		CAFile:             config.CAFile,
+		CertFile:           config.CertFile,
+		KeyFile:            config.KeyFile,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+}
+
+func newCloudWatchAlarmMetrics(config Config) (*metrics.CloudWatchAlarmMetrics, error) {
+	return metrics.NewCloudWatchAlarmMetrics(&metrics.CloudWatchAlarmConfig{
+		Sess:            awsSession,
+		AlarmNames:      config.Metrics.CloudWatchMetricsConfig.AlarmNames,
+		AlarmNamePrefix: config.Metrics.CloudWatchMetricsConfig.AlarmNamePrefix,
+	})
+}
+
+// newGuard returns the CloudWatch alarms checked in addition to the metrics, or nil if there are none.
+func newGuard(config Config) (metrics.Metrics, error) {
+	if config.Metrics.Type == metrics.CloudWatchAlarmMetricsType {
+		return nil, nil
+	}
+	if len(config.Metrics.CloudWatchMetricsConfig.AlarmNames) == 0 && config.Metrics.CloudWatchMetricsConfig.AlarmNamePrefix == "" {
+		return nil, nil
+	}
+	return newCloudWatchAlarmMetrics(config)
+}
+
+func quoteJoin(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, ", ")
+}
+
 func newAlgorithm(config Config) (algorithm.Algorithm, error) {
 	var algo algorithm.Algorithm
 	switch config.Algorithm.Type {
@@ -154,5 +1336,19 @@ func newQueryBuilder(config Config) (*metrics.QueryBuilder, error) {
 }
 
 func newFomura(config Config) (*formura.Formula, error) {
-	return formura.NewFormula(config.Metrics.Condition), nil
+	condition := config.Metrics.Condition
+	if condition == "" && config.Metrics.Type == metrics.CloudWatchAlarmMetricsType {
+		condition = metrics.CloudWatchAlarmCondition
+	}
+	if condition == "" && config.Metrics.Type == metrics.Route53HealthCheckMetricsType {
+		condition = metrics.Route53HealthCheckCondition
+	}
+	if condition == "" && config.Metrics.Type == metrics.CloudWatchMetricsType && config.Metrics.CloudWatchMetricsConfig.Preset != "" {
+		preset, err := metrics.LookupCloudWatchPreset(config.Metrics.CloudWatchMetricsConfig.Preset)
+		if err != nil {
+			return nil, fmt.Errorf("--cloudwatch-preset: %w", err)
+		}
+		condition = preset.Condition
+	}
+	return formura.NewFormula(condition), nil
 }
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"github.com/k-kinzal/progressived/pkg/progressived"
 	"github.com/spf13/cobra"
 )
 
@@ -17,40 +16,11 @@ var (
 )
 
 func rollbackRun(*cobra.Command, []string) error {
-	pv, err := newProvider(config)
+	p, err := newProgressived(config, true)
 	if err != nil {
 		return err
 	}
 
-	ms, err := newMetrics(config)
-	if err != nil {
-		return err
-	}
-
-	qb, err := newQueryBuilder(config)
-	if err != nil {
-		return err
-	}
-
-	ag, err := newAlgorithm(config)
-	if err != nil {
-		return err
-	}
-
-	fm, err := newFomura(config)
-	if err != nil {
-		return err
-	}
-
-	p := &progressived.Progressived{
-		Provider:    pv,
-		Metrics:     ms,
-		Builder:     qb,
-		Algorithm:   ag,
-		Formura:     fm,
-		AllowNoData: config.Metrics.AllowNoData,
-	}
-
 	if _, err := p.Rollback(); err != nil {
 		return err
 	}
@@ -10,6 +10,8 @@ import (
 )
 
 var (
+	configFile string
+
 	// aws credentials
 	awsRegion          string
 	awsProfile         string
@@ -21,6 +23,13 @@ var (
 		Use:   "progressived",
 		Short: "Daemon for progressive delivery",
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(cmd, configFile); err != nil {
+				return err
+			}
+			if err := registerCloudWatchPresets(config.Metrics.CloudWatchMetricsConfig.Presets); err != nil {
+				return err
+			}
+
 			// aws credentials
 			c := &aws.Config{}
 			if awsRegion != "" {
@@ -53,6 +62,7 @@ var (
 
 func init() {
 	rootCmd.SetVersionTemplate(`{{printf "%s" .Version}}`)
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Path to the YAML configuration file")
 	rootCmd.PersistentFlags().StringVar(&awsRegion, "aws-region", "", "Using a specific profile from an AWS credential file")
 	rootCmd.PersistentFlags().StringVar(&awsProfile, "aws-profile", "", "The AWS region to use. overrides the configuration in config/env")
 	rootCmd.PersistentFlags().StringVar(&awsAccessKeyId, "aws-access-key-id", "", "AWS access key ID. overrides the configuration in config/env.")
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"github.com/k-kinzal/progressived/pkg/controller"
+	"github.com/k-kinzal/progressived/pkg/logger"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var (
+	runInterval time.Duration
+
+	runCmd = &cobra.Command{
+		Use:           "run",
+		Short:         "[experimental] Keep updating the routing policy at the interval, and roll it back while the metrics do not match the criteria",
+		RunE:          runRun,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+)
+
+func runRun(*cobra.Command, []string) error {
+	// the metrics keep receiving and probing in the background between the evaluations
+	p, err := newProgressived(config, false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, m := range []interface{}{p.Metrics, p.Guard} {
+			if c, ok := m.(io.Closer); ok {
+				c.Close()
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	controller.NewController(p, runInterval, logger.NewStandardLogger(os.Stderr)).Run(ctx)
+
+	return nil
+}
+
+func init() {
+	runCmd = setFlags(runCmd)
+	runCmd.Flags().DurationVar(&runInterval, "interval", time.Minute, "Interval between the updates of the routing policy")
+	rootCmd.AddCommand(runCmd)
+}
@@ -4,8 +4,21 @@ go 1.14
 
 require (
 	github.com/Knetic/govaluate v3.0.0+incompatible
-	github.com/aws/aws-sdk-go v1.34.16
+	github.com/aws/aws-sdk-go v1.55.8
 	github.com/cenkalti/backoff/v4 v4.0.2
 	github.com/fatih/structs v1.1.0
+	github.com/go-sql-driver/mysql v1.5.0
+	github.com/lib/pq v1.8.0
+	github.com/mattn/go-sqlite3 v1.14.4
+	github.com/pelletier/go-toml v1.9.5
 	github.com/spf13/cobra v1.0.0
+	github.com/spf13/pflag v1.0.5
+	go.opentelemetry.io/proto/otlp v0.9.0
+	google.golang.org/grpc v1.37.1
+	google.golang.org/protobuf v1.26.0
+	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
+	k8s.io/api v0.21.14
+	k8s.io/apimachinery v0.21.14
+	k8s.io/client-go v0.21.14
 )
@@ -96,10 +96,13 @@ func (c *Controller) Run(ctx context.Context) {
 }
 
 func NewController(prog *progressived.Progressived, interval time.Duration, logger logger.Logger) *Controller {
+	b := backoff.NewExponentialBackOff()
+	// the controller keeps running, so the backoff never stops
+	b.MaxElapsedTime = 0
 	return &Controller{
 		progressived: prog,
 		scheduler:    NewScheduler(),
-		backoff:      backoff.NewExponentialBackOff(),
+		backoff:      b,
 		interval:     interval,
 		logger:       logger,
 	}
@@ -51,13 +51,26 @@ func (s *Scheduler) Remove(scheduleTime time.Time, jobFunc JobFunc) {
 	for i, job := range s.jobs {
 		if scheduleTime.Before(job.scheduleTime) || scheduleTime.Equal(job.scheduleTime) {
 			if runtime.FuncForPC(reflect.ValueOf(jobFunc).Pointer()).Name() == runtime.FuncForPC(reflect.ValueOf(job.jobFunc).Pointer()).Name() {
-				s.jobs = append(s.jobs[:i], s.jobs[i:]...)
+				s.jobs = append(s.jobs[:i], s.jobs[i+1:]...)
 			}
 			break
 		}
 	}
 }
 
+// pop removes and returns the first job if it is due at t.
+func (s *Scheduler) pop(t time.Time) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.jobs) == 0 || t.Before(s.jobs[0].scheduleTime) {
+		return nil
+	}
+	next := s.jobs[0]
+	s.jobs = s.jobs[1:]
+	return next
+}
+
 func (s *Scheduler) Start(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
@@ -66,10 +79,9 @@ func (s *Scheduler) Start(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case t := <-ticker.C:
-			next := s.jobs[0]
-			if t.Before(next.scheduleTime) || t.Equal(next.scheduleTime) {
+			// a job is removed before it runs, because it may add the next job
+			for next := s.pop(t); next != nil; next = s.pop(t) {
 				next.jobFunc()
-				s.Remove(next.scheduleTime, next.jobFunc)
 			}
 		}
 	}
@@ -1,6 +1,7 @@
 package controller_test
 
 import (
+	"context"
 	"github.com/k-kinzal/progressived/pkg/controller"
 	"testing"
 	"time"
@@ -17,3 +18,25 @@ func TestScheduler_Add(t *testing.T) {
 	t4, _ := time.Parse("2006-01-02", "2019-12-31")
 	scheduler.Add(t4, func() {})
 }
+
+func TestScheduler_Start(t *testing.T) {
+	scheduler := controller.NewScheduler()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs []string
+	now := time.Now()
+	scheduler.Add(now.Add(time.Hour), func() { runs = append(runs, "later") })
+	scheduler.Add(now, func() {
+		runs = append(runs, "first")
+		scheduler.Add(time.Now(), func() {
+			runs = append(runs, "second")
+			cancel()
+		})
+	})
+	scheduler.Start(ctx)
+
+	if len(runs) != 2 || runs[0] != "first" || runs[1] != "second" {
+		t.Errorf("expected the due jobs to run in order, but got %v", runs)
+	}
+}
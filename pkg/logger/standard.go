@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log"
+)
+
+// StandardLogger writes the logs with the standard log package. The fields are written as `key=value` after the level.
+type StandardLogger struct {
+	logger *log.Logger
+	fields string
+}
+
+func (l *StandardLogger) WithField(key string, value interface{}) Logger {
+	return &StandardLogger{
+		logger: l.logger,
+		fields: fmt.Sprintf("%s %s=%v", l.fields, key, value),
+	}
+}
+
+func (l *StandardLogger) output(level string, msg string) {
+	l.logger.Printf("%s%s %s", level, l.fields, msg)
+}
+
+func (l *StandardLogger) Debug(args ...interface{}) {
+	l.output("DEBUG", fmt.Sprint(args...))
+}
+
+func (l *StandardLogger) Debugf(format string, args ...interface{}) {
+	l.output("DEBUG", fmt.Sprintf(format, args...))
+}
+
+func (l *StandardLogger) Info(args ...interface{}) {
+	l.output("INFO", fmt.Sprint(args...))
+}
+
+func (l *StandardLogger) Infof(format string, args ...interface{}) {
+	l.output("INFO", fmt.Sprintf(format, args...))
+}
+
+func (l *StandardLogger) Warn(args ...interface{}) {
+	l.output("WARN", fmt.Sprint(args...))
+}
+
+func (l *StandardLogger) Warnf(format string, args ...interface{}) {
+	l.output("WARN", fmt.Sprintf(format, args...))
+}
+
+func (l *StandardLogger) Error(args ...interface{}) {
+	l.output("ERROR", fmt.Sprint(args...))
+}
+
+func (l *StandardLogger) Errorf(format string, args ...interface{}) {
+	l.output("ERROR", fmt.Sprintf(format, args...))
+}
+
+func NewStandardLogger(w io.Writer) *StandardLogger {
+	return &StandardLogger{
+		logger: log.New(w, "", log.LstdFlags),
+	}
+}
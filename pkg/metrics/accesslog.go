@@ -0,0 +1,407 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AccessLogMetricsType = "accesslog"
+
+	// AccessLogFormatCommon is the Common Log Format.
+	AccessLogFormatCommon = "common"
+	// AccessLogFormatCombined is the Combined Log Format. The request time in
+	// seconds and the upstream address may follow it, e.g. nginx
+	// `... "$http_user_agent" $request_time $upstream_addr`.
+	AccessLogFormatCombined = "combined"
+	// AccessLogFormatJSON is a JSON object per line.
+	AccessLogFormatJSON = "json"
+
+	AccessLogQueryErrorRate    = "error_rate"
+	AccessLogQueryErrorCount   = "error_count"
+	AccessLogQueryRequestCount = "request_count"
+	// AccessLogQueryLatency is followed by a reduction, e.g. `latency_p99` or `latency_average`.
+	AccessLogQueryLatency = "latency_"
+
+	accessLogDefaultErrorStatus = 500
+	accessLogTimeLayout         = "02/Jan/2006:15:04:05 -0700"
+)
+
+var accessLogRegexp = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "[^"]*" (\d{3}) \S+(?: "[^"]*" "[^"]*")?(?: (\S+))?(?: (.+))?$`)
+
+// AccessLogFields are the names of the fields in AccessLogFormatJSON.
+type AccessLogFields struct {
+	// Time is RFC3339, the Common Log Format time or UNIX seconds. Defaults to `time`.
+	Time string
+	// Status defaults to `status`.
+	Status string
+	// Latency is in seconds. Defaults to `request_time`.
+	Latency string
+	// Upstream defaults to `upstream_addr`.
+	Upstream string
+}
+
+type AccessLogConfig struct {
+	// Paths are the access log files. Glob patterns are allowed to follow rotated files.
+	Paths  []string
+	Format string
+	Fields AccessLogFields
+	// Upstream filters the requests by the upstream address, e.g. to the destination only.
+	// It is compared with each address in the field, e.g. `10.0.1.2:80, 10.0.1.3:80`, and
+	// matches the host of an address when it has no port.
+	Upstream string
+	// ErrorStatus is the smallest status code counted as an error. Defaults to 500.
+	ErrorStatus int
+
+	Period time.Duration
+}
+
+type accessLogEntry struct {
+	time   time.Time
+	status int
+	// latency is valid only when hasLatency is set, e.g. nginx logs `-` for a request that was not proxied
+	latency    float64
+	hasLatency bool
+	upstream   string
+}
+
+type accessLogFile struct {
+	info    os.FileInfo
+	offset  int64
+	partial string
+}
+
+// AccessLogMetrics tails access log files and aggregates the requests in the collection period in memory.
+type AccessLogMetrics struct {
+	mu          sync.Mutex
+	paths       []string
+	format      string
+	fields      AccessLogFields
+	upstream    string
+	errorStatus int
+	period      time.Duration
+	files       []*accessLogFile
+	entries     []accessLogEntry
+}
+
+// readLines calls fn with each complete line appended to the file since the last read.
+func (f *accessLogFile) readLines(fp *os.File, info os.FileInfo, fn func(line string)) error {
+	// the file was truncated
+	if info.Size() < f.offset {
+		f.offset = 0
+		f.partial = ""
+	}
+	f.info = info
+
+	if _, err := fp.Seek(f.offset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(fp)
+	for {
+		line, err := r.ReadString('\n')
+		f.offset += int64(len(line))
+		if err == io.EOF {
+			f.partial += line
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(f.partial + line[:len(line)-1])
+		f.partial = ""
+	}
+}
+
+func parseAccessLogTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(accessLogTimeLayout, s); err == nil {
+		return t, nil
+	}
+	if sec, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Unix(0, int64(sec*float64(time.Second))), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time `%s`", s)
+}
+
+func (m *AccessLogMetrics) parseText(line string) (*accessLogEntry, error) {
+	match := accessLogRegexp.FindStringSubmatch(line)
+	if match == nil {
+		return nil, errors.New("line does not match the format")
+	}
+	t, err := time.Parse(accessLogTimeLayout, match[1])
+	if err != nil {
+		return nil, err
+	}
+	status, _ := strconv.Atoi(match[2])
+	entry := &accessLogEntry{time: t, status: status, upstream: match[4]}
+	if latency, err := strconv.ParseFloat(match[3], 64); err == nil {
+		entry.latency, entry.hasLatency = latency, true
+	}
+	return entry, nil
+}
+
+func (m *AccessLogMetrics) parseJSON(line string) (*accessLogEntry, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &doc); err != nil {
+		return nil, err
+	}
+
+	entry := &accessLogEntry{}
+	switch t := doc[m.fields.Time].(type) {
+	case string:
+		tm, err := parseAccessLogTime(t)
+		if err != nil {
+			return nil, err
+		}
+		entry.time = tm
+	case float64:
+		entry.time = time.Unix(0, int64(t*float64(time.Second)))
+	default:
+		return nil, fmt.Errorf("field `%s` is missing", m.fields.Time)
+	}
+	status, err := jsonNumber(doc[m.fields.Status])
+	if err != nil {
+		return nil, fmt.Errorf("field `%s`: %w", m.fields.Status, err)
+	}
+	entry.status = int(status)
+	if latency, err := jsonNumber(doc[m.fields.Latency]); err == nil {
+		entry.latency, entry.hasLatency = latency, true
+	}
+	if upstream, ok := doc[m.fields.Upstream].(string); ok {
+		entry.upstream = upstream
+	}
+
+	return entry, nil
+}
+
+// collect reads the new lines of the files and drops the entries outside of the collection period.
+func (m *AccessLogMetrics) collect(now time.Time) error {
+	var paths []string
+	for _, pattern := range m.paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid access log path `%s`: %w", pattern, err)
+		}
+		paths = append(paths, matches...)
+	}
+
+	since := now.Add(-m.period)
+	handle := func(line string) {
+		if strings.TrimSpace(line) == "" {
+			return
+		}
+		var entry *accessLogEntry
+		var err error
+		if m.format == AccessLogFormatJSON {
+			entry, err = m.parseJSON(line)
+		} else {
+			entry, err = m.parseText(line)
+		}
+		// lines in other formats, e.g. error messages, are skipped
+		if err != nil || entry.time.Before(since) {
+			return
+		}
+		m.entries = append(m.entries, *entry)
+	}
+
+	// the files are tracked by their identity rather than their path so that a rotated file
+	// is read on from its offset and the new file at the path is read from the beginning
+	var files []*accessLogFile
+	var collectErr error
+	for _, path := range paths {
+		// the other files are still read to keep their offsets in step with the entries
+		if err := m.collectFile(path, &files, handle); err != nil && collectErr == nil {
+			collectErr = fmt.Errorf("failed to read access log `%s`: %w", path, err)
+		}
+	}
+	// the files no longer matched by the paths, e.g. removed by the rotation, are forgotten
+	m.files = files
+
+	entries := m.entries[:0]
+	for _, e := range m.entries {
+		if !e.time.Before(since) {
+			entries = append(entries, e)
+		}
+	}
+	m.entries = entries
+
+	return collectErr
+}
+
+func (m *AccessLogMetrics) collectFile(path string, files *[]*accessLogFile, fn func(line string)) error {
+	fp, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer fp.Close()
+
+	info, err := fp.Stat()
+	if err != nil {
+		return err
+	}
+	// the same file may be matched by several patterns
+	for _, f := range *files {
+		if os.SameFile(f.info, info) {
+			return nil
+		}
+	}
+	var file *accessLogFile
+	for _, f := range m.files {
+		if os.SameFile(f.info, info) {
+			file = f
+			break
+		}
+	}
+	if file == nil {
+		file = &accessLogFile{}
+	}
+	*files = append(*files, file)
+
+	return file.readLines(fp, info, fn)
+}
+
+// matchUpstream reports whether one of the addresses in the upstream field is the upstream.
+func matchUpstream(field string, upstream string) bool {
+	// nginx separates the addresses of the retried servers by `, ` and of the internal redirects by ` : `
+	for _, addr := range strings.FieldsFunc(field, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if addr == ":" {
+			continue
+		}
+		if addr == upstream {
+			return true
+		}
+		if host, _, err := net.SplitHostPort(addr); err == nil && host == upstream {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMetric returns the statistic named by the query over the requests in the collection period:
+// `error_rate` in percent, `error_count`, `request_count` or `latency_` followed by a reduction such as `latency_p99`.
+func (m *AccessLogMetrics) GetMetric(query string) (float64, error) {
+	q := strings.TrimSpace(query)
+	var reduction *Reduction
+	switch {
+	case q == AccessLogQueryErrorRate, q == AccessLogQueryErrorCount, q == AccessLogQueryRequestCount:
+	case strings.HasPrefix(q, AccessLogQueryLatency):
+		r, err := ParseReduction(strings.TrimPrefix(q, AccessLogQueryLatency))
+		if err != nil {
+			return 0, fmt.Errorf("invalid access log query `%s`: %w", q, err)
+		}
+		if m.format == AccessLogFormatCommon {
+			return 0, fmt.Errorf("access log query `%s` is not available in the \"%s\" format because it has no latency", q, AccessLogFormatCommon)
+		}
+		reduction = r
+	default:
+		return 0, fmt.Errorf("access log query can be either \"%s\", \"%s\", \"%s\" or \"%s\" followed by a reduction, but got `%s`", AccessLogQueryErrorRate, AccessLogQueryErrorCount, AccessLogQueryRequestCount, AccessLogQueryLatency, q)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.collect(time.Now()); err != nil {
+		return 0, err
+	}
+
+	var entries []accessLogEntry
+	for _, e := range m.entries {
+		if m.upstream == "" || matchUpstream(e.upstream, m.upstream) {
+			entries = append(entries, e)
+		}
+	}
+	// the files are read one after another, so the entries are ordered by the time for the latest
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].time.Before(entries[j].time)
+	})
+
+	requests, errs := len(entries), 0
+	var latencies []float64
+	for _, e := range entries {
+		if e.status >= m.errorStatus {
+			errs++
+		}
+		if e.hasLatency {
+			latencies = append(latencies, e.latency)
+		}
+	}
+
+	switch q {
+	case AccessLogQueryRequestCount:
+		return float64(requests), nil
+	case AccessLogQueryErrorCount:
+		return float64(errs), nil
+	}
+	if requests == 0 {
+		return 0, &NoDataError{query: q}
+	}
+	if q == AccessLogQueryErrorRate {
+		return float64(errs) / float64(requests) * 100, nil
+	}
+	if len(latencies) == 0 {
+		return 0, &NoDataError{query: q}
+	}
+	return reduction.Reduce(latencies)
+}
+
+func NewAccessLogMetrics(config *AccessLogConfig) (*AccessLogMetrics, error) {
+	if len(config.Paths) == 0 {
+		return nil, errors.New("AccessLogConfig.Paths is missing")
+	}
+	format := config.Format
+	if format == "" {
+		format = AccessLogFormatCombined
+	}
+	switch format {
+	case AccessLogFormatCommon, AccessLogFormatCombined, AccessLogFormatJSON:
+	default:
+		return nil, fmt.Errorf("AccessLogConfig.Format can be either \"%s\", \"%s\", \"%s\"", AccessLogFormatCommon, AccessLogFormatCombined, AccessLogFormatJSON)
+	}
+	if config.Period <= 0 {
+		return nil, errors.New("AccessLogConfig.Period must be greater than 0")
+	}
+
+	fields := config.Fields
+	if fields.Time == "" {
+		fields.Time = "time"
+	}
+	if fields.Status == "" {
+		fields.Status = "status"
+	}
+	if fields.Latency == "" {
+		fields.Latency = "request_time"
+	}
+	if fields.Upstream == "" {
+		fields.Upstream = "upstream_addr"
+	}
+	errorStatus := config.ErrorStatus
+	if errorStatus == 0 {
+		errorStatus = accessLogDefaultErrorStatus
+	}
+
+	return &AccessLogMetrics{
+		paths:       config.Paths,
+		format:      format,
+		fields:      fields,
+		upstream:    config.Upstream,
+		errorStatus: errorStatus,
+		period:      config.Period,
+	}, nil
+}
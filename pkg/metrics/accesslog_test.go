@@ -0,0 +1,252 @@
+package metrics_test
+
+import (
+	"fmt"
+	"github.com/k-kinzal/progressived/pkg/metrics"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func appendLines(t *testing.T, path string, lines ...string) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(f, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func getMetric(t *testing.T, m metrics.Metrics, query string) float64 {
+	v, err := m.GetMetric(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestAccessLogMetrics_GetMetric_Combined(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accesslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+
+	now := time.Now().Format("02/Jan/2006:15:04:05 -0700")
+	old := time.Now().Add(-time.Hour).Format("02/Jan/2006:15:04:05 -0700")
+	line := `10.0.0.1 - - [%s] "GET / HTTP/1.1" %d 612 "-" "curl/7.64.1" %s %s`
+	appendLines(t, path,
+		fmt.Sprintf(line, old, 500, "0.100", "10.0.1.2:80"),
+		fmt.Sprintf(line, now, 200, "0.100", "10.0.1.1:80"),
+		fmt.Sprintf(line, now, 200, "0.200", "10.0.1.2:80"),
+		fmt.Sprintf(line, now, 502, "0.300", "10.0.1.2:80"),
+	)
+
+	m, err := metrics.NewAccessLogMetrics(&metrics.AccessLogConfig{
+		Paths:    []string{filepath.Join(dir, "*.log")},
+		Upstream: "10.0.1.2",
+		Period:   5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := getMetric(t, m, "request_count"); v != 2 {
+		t.Errorf("expected 2 requests, but got %f", v)
+	}
+	if v := getMetric(t, m, "error_rate"); v != 50 {
+		t.Errorf("expected error rate 50, but got %f", v)
+	}
+	if v := getMetric(t, m, "latency_max"); v != 0.3 {
+		t.Errorf("expected max latency 0.3, but got %f", v)
+	}
+
+	// rotated file starts from the beginning
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLines(t, path, fmt.Sprintf(line, now, 200, "0.400", "10.0.1.2:80"))
+	if v := getMetric(t, m, "request_count"); v != 3 {
+		t.Errorf("expected 3 requests, but got %f", v)
+	}
+}
+
+func TestAccessLogMetrics_GetMetric_JSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accesslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.json")
+
+	m, err := metrics.NewAccessLogMetrics(&metrics.AccessLogConfig{
+		Paths:  []string{path},
+		Format: metrics.AccessLogFormatJSON,
+		Period: 5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetMetric("error_rate"); err == nil {
+		t.Error("expected NoDataError")
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	appendLines(t, path,
+		fmt.Sprintf(`{"time":"%s","status":200,"request_time":"0.010","upstream_addr":"green"}`, now),
+		fmt.Sprintf(`{"time":"%s","status":404,"request_time":"0.020","upstream_addr":"green"}`, now),
+	)
+	if v := getMetric(t, m, "error_rate"); v != 0 {
+		t.Errorf("expected error rate 0, but got %f", v)
+	}
+	if v := getMetric(t, m, "latency_average"); v < 0.0149 || v > 0.0151 {
+		t.Errorf("expected average latency 0.015, but got %f", v)
+	}
+}
+
+func TestAccessLogMetrics_GetMetric_Rotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accesslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+
+	now := time.Now().Format("02/Jan/2006:15:04:05 -0700")
+	line := `10.0.0.1 - - [%s] "GET / HTTP/1.1" %d 612 "-" "curl/7.64.1" 0.100 %s`
+	appendLines(t, path, fmt.Sprintf(line, now, 200, "10.0.1.2:80"))
+
+	m, err := metrics.NewAccessLogMetrics(&metrics.AccessLogConfig{
+		Paths:  []string{path, path + ".*"},
+		Period: 5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := getMetric(t, m, "request_count"); v != 1 {
+		t.Errorf("expected 1 request, but got %f", v)
+	}
+
+	// the rotated file is read on from its offset rather than from the beginning
+	appendLines(t, path, fmt.Sprintf(line, now, 500, "10.0.1.2:80"))
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLines(t, path, fmt.Sprintf(line, now, 200, "10.0.1.2:80"))
+	if v := getMetric(t, m, "request_count"); v != 3 {
+		t.Errorf("expected 3 requests, but got %f", v)
+	}
+	if v := getMetric(t, m, "error_count"); v != 1 {
+		t.Errorf("expected 1 error, but got %f", v)
+	}
+
+	// a line is counted once it is complete
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entry := fmt.Sprintf(line, now, 200, "10.0.1.2:80")
+	if _, err := f.WriteString(entry[:10]); err != nil {
+		t.Fatal(err)
+	}
+	if v := getMetric(t, m, "request_count"); v != 3 {
+		t.Errorf("expected 3 requests, but got %f", v)
+	}
+	if _, err := f.WriteString(entry[10:] + "\n"); err != nil {
+		t.Fatal(err)
+	}
+	if v := getMetric(t, m, "request_count"); v != 4 {
+		t.Errorf("expected 4 requests, but got %f", v)
+	}
+}
+
+func TestAccessLogMetrics_GetMetric_Upstream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accesslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+
+	now := time.Now().Format("02/Jan/2006:15:04:05 -0700")
+	line := `10.0.0.1 - - [%s] "GET / HTTP/1.1" 200 612 "-" "curl/7.64.1" 0.100 %s`
+	appendLines(t, path,
+		fmt.Sprintf(line, now, "10.0.1.2:80"),
+		fmt.Sprintf(line, now, "10.0.1.20:80"),
+		fmt.Sprintf(line, now, "10.0.1.3:80, 10.0.1.2:80"),
+		fmt.Sprintf(line, now, "10.0.1.3:8080"),
+	)
+
+	for upstream, expected := range map[string]float64{"10.0.1.2": 2, "10.0.1.3:80": 1, "10.0.1.3": 2, "10.0.1.2:8": 0} {
+		m, err := metrics.NewAccessLogMetrics(&metrics.AccessLogConfig{
+			Paths:    []string{path},
+			Upstream: upstream,
+			Period:   5 * time.Minute,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := getMetric(t, m, "request_count"); v != expected {
+			t.Errorf("%s: expected %f requests, but got %f", upstream, expected, v)
+		}
+	}
+}
+
+func TestAccessLogMetrics_GetMetric_CommonLatency(t *testing.T) {
+	m, err := metrics.NewAccessLogMetrics(&metrics.AccessLogConfig{
+		Paths:  []string{"access.log"},
+		Format: metrics.AccessLogFormatCommon,
+		Period: 5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetMetric("latency_p99"); err == nil {
+		t.Error("expected an error because the common log format has no latency")
+	}
+}
+
+func TestAccessLogMetrics_GetMetric_Latency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accesslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	line := `{"time":"%s","status":200%s}`
+	// the newest entry is in the file read first
+	appendLines(t, filepath.Join(dir, "a.json"),
+		fmt.Sprintf(line, now.Format(time.RFC3339Nano), `,"request_time":0.3`),
+	)
+	appendLines(t, filepath.Join(dir, "b.json"),
+		fmt.Sprintf(line, now.Add(-2*time.Second).Format(time.RFC3339Nano), `,"request_time":0.1`),
+		fmt.Sprintf(line, now.Add(-time.Second).Format(time.RFC3339Nano), ""),
+	)
+
+	m, err := metrics.NewAccessLogMetrics(&metrics.AccessLogConfig{
+		Paths:  []string{filepath.Join(dir, "*.json")},
+		Format: metrics.AccessLogFormatJSON,
+		Period: 5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := getMetric(t, m, "request_count"); v != 3 {
+		t.Errorf("expected 3 requests, but got %f", v)
+	}
+	if v := getMetric(t, m, "latency_latest"); v != 0.3 {
+		t.Errorf("expected the latency of the newest entry 0.3, but got %f", v)
+	}
+	// the entry without a latency is not counted as 0
+	if v := getMetric(t, m, "latency_min"); v != 0.1 {
+		t.Errorf("expected min latency 0.1, but got %f", v)
+	}
+}
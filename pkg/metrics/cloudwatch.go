@@ -2,10 +2,13 @@ package metrics
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -16,49 +19,144 @@ const (
 type CloudWatchConfig struct {
 	Sess *session.Session
 
+	Client CloudWatchClient
+
 	Period time.Duration
+	// Reduction reduces the datapoints of the selected result. Defaults to the latest datapoint.
+	Reduction *Reduction
+	// ResultId is the Id of the query whose result is used.
+	// Defaults to the first query that returns data.
+	ResultId string
+	// Queries are used instead of the query passed to GetMetric when it is empty.
+	Queries []*CloudWatchQuery
+}
+
+type CloudWatchClient interface {
+	GetMetricData(input *cloudwatch.GetMetricDataInput) (*cloudwatch.GetMetricDataOutput, error)
 }
 
 type CloudWatchMetrics struct {
-	client *cloudwatch.CloudWatch
-	period time.Duration
+	client    CloudWatchClient
+	period    time.Duration
+	reduction *Reduction
+	resultId  string
+	queries   []*cloudwatch.MetricDataQuery
 }
 
-func (m *CloudWatchMetrics) GetMetric(query string) (float64, error) {
-	var queries []*cloudwatch.MetricDataQuery
-	if err := json.Unmarshal([]byte(query), &queries); err != nil {
-		return 0, fmt.Errorf("unmarshal to cloudwatch.MetricDataQuery failed: %w", err)
+type cloudWatchDatapoint struct {
+	timestamp time.Time
+	value     float64
+}
+
+func (m *CloudWatchMetrics) selectResultId(queries []*cloudwatch.MetricDataQuery) (string, error) {
+	if m.resultId != "" {
+		return m.resultId, nil
+	}
+	for _, q := range queries {
+		if q.ReturnData == nil || aws.BoolValue(q.ReturnData) {
+			return aws.StringValue(q.Id), nil
+		}
+	}
+	return "", errors.New("no query returns data")
+}
+
+// GetMetricSeries returns all datapoints of the selected result ordered from the oldest to the latest.
+func (m *CloudWatchMetrics) GetMetricSeries(query string) ([]float64, error) {
+	queries := m.queries
+	if strings.TrimSpace(query) != "" || len(queries) == 0 {
+		queries = nil
+		if err := json.Unmarshal([]byte(query), &queries); err != nil {
+			return nil, fmt.Errorf("unmarshal to cloudwatch.MetricDataQuery failed: %w", err)
+		}
+	}
+	id, err := m.selectResultId(queries)
+	if err != nil {
+		return nil, err
 	}
 
 	end := time.Now()
 	start := end.Add(-m.period)
-	res, err := m.client.GetMetricData(&cloudwatch.GetMetricDataInput{
+	input := &cloudwatch.GetMetricDataInput{
 		EndTime:           aws.Time(end),
-		MaxDatapoints:     aws.Int64(20),
 		StartTime:         aws.Time(start),
 		MetricDataQueries: queries,
-	})
-	if err != nil {
-		return 0, fmt.Errorf("failed to get cloudwatch metrics: %w", err)
+		ScanBy:            aws.String(cloudwatch.ScanByTimestampAscending),
+	}
+	var datapoints []cloudWatchDatapoint
+	for {
+		res, err := m.client.GetMetricData(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cloudwatch metrics: %w", err)
+		}
+		for _, r := range res.MetricDataResults {
+			if aws.StringValue(r.Id) != id {
+				continue
+			}
+			for i, v := range r.Values {
+				dp := cloudWatchDatapoint{value: aws.Float64Value(v)}
+				if i < len(r.Timestamps) {
+					dp.timestamp = aws.TimeValue(r.Timestamps[i])
+				}
+				datapoints = append(datapoints, dp)
+			}
+		}
+		if res.NextToken == nil {
+			break
+		}
+		input.NextToken = res.NextToken
+	}
+	if len(datapoints) < 1 {
+		return nil, &NoDataError{query: query}
 	}
 
-	metrics := res.MetricDataResults
-	if len(metrics) < 1 {
-		return 0, &NoDataError{query: query}
+	sort.SliceStable(datapoints, func(i, j int) bool {
+		return datapoints[i].timestamp.Before(datapoints[j].timestamp)
+	})
+	values := make([]float64, len(datapoints))
+	for i, dp := range datapoints {
+		values[i] = dp.value
 	}
-	values := metrics[0].Values
-	if len(values) < 1 {
-		return 0, &NoDataError{query: query}
+
+	return values, nil
+}
+
+func (m *CloudWatchMetrics) GetMetric(query string) (float64, error) {
+	values, err := m.GetMetricSeries(query)
+	if err != nil {
+		return 0, err
 	}
 
-	return aws.Float64Value(values[0]), nil
+	return m.reduction.Reduce(values)
 }
 
-func NewCloudWatchMetrics(config *CloudWatchConfig) *CloudWatchMetrics {
-	client := cloudwatch.New(config.Sess)
+func NewCloudWatchMetrics(config *CloudWatchConfig) (*CloudWatchMetrics, error) {
+	reduction := config.Reduction
+	if reduction == nil {
+		reduction = &Reduction{name: ReductionLatest}
+	}
 
-	return &CloudWatchMetrics{
-		client: client,
-		period: config.Period,
+	var queries []*cloudwatch.MetricDataQuery
+	if len(config.Queries) > 0 {
+		q, err := CompileCloudWatchQueries(config.Queries)
+		if err != nil {
+			return nil, fmt.Errorf("CloudWatchConfig.Queries: %w", err)
+		}
+		queries = q
 	}
+
+	client := config.Client
+	if client == nil {
+		if config.Sess == nil {
+			return nil, errors.New("CloudWatchConfig.Sess must be set when CloudWatchConfig.Client is missing")
+		}
+		client = cloudwatch.New(config.Sess)
+	}
+
+	return &CloudWatchMetrics{
+		client:    client,
+		period:    config.Period,
+		reduction: reduction,
+		resultId:  config.ResultId,
+		queries:   queries,
+	}, nil
 }
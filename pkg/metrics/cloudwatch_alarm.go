@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"strings"
+)
+
+const (
+	CloudWatchAlarmMetricsType = "cloudwatch-alarm"
+
+	// CloudWatchAlarmCondition is the condition that passes when no alarm is in ALARM state.
+	CloudWatchAlarmCondition = "x == 0"
+
+	cloudWatchMaxAlarmNames = 100
+)
+
+type CloudWatchAlarmConfig struct {
+	Sess *session.Session
+
+	Client CloudWatchAlarmClient
+
+	AlarmNames      []string
+	AlarmNamePrefix string
+}
+
+type CloudWatchAlarmClient interface {
+	DescribeAlarms(input *cloudwatch.DescribeAlarmsInput) (*cloudwatch.DescribeAlarmsOutput, error)
+}
+
+// CloudWatchAlarmMetrics returns the number of alarms in ALARM state.
+type CloudWatchAlarmMetrics struct {
+	client     CloudWatchAlarmClient
+	alarmNames []string
+	prefix     string
+}
+
+func (m *CloudWatchAlarmMetrics) describeAlarms(input *cloudwatch.DescribeAlarmsInput) (states map[string]string, err error) {
+	states = make(map[string]string)
+	input.AlarmTypes = aws.StringSlice([]string{cloudwatch.AlarmTypeMetricAlarm, cloudwatch.AlarmTypeCompositeAlarm})
+	for {
+		res, err := m.client.DescribeAlarms(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe cloudwatch alarms: %w", err)
+		}
+		for _, a := range res.MetricAlarms {
+			states[aws.StringValue(a.AlarmName)] = aws.StringValue(a.StateValue)
+		}
+		for _, a := range res.CompositeAlarms {
+			states[aws.StringValue(a.AlarmName)] = aws.StringValue(a.StateValue)
+		}
+		if res.NextToken == nil {
+			break
+		}
+		input.NextToken = res.NextToken
+	}
+	return states, nil
+}
+
+// GetMetric returns the number of alarms in ALARM state. The query is an
+// optional list of additional alarm names separated by commas or whitespace.
+func (m *CloudWatchAlarmMetrics) GetMetric(query string) (float64, error) {
+	names := append([]string{}, m.alarmNames...)
+	names = append(names, strings.FieldsFunc(query, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})...)
+
+	states := make(map[string]string)
+	for i := 0; i < len(names); i += cloudWatchMaxAlarmNames {
+		end := i + cloudWatchMaxAlarmNames
+		if end > len(names) {
+			end = len(names)
+		}
+		s, err := m.describeAlarms(&cloudwatch.DescribeAlarmsInput{AlarmNames: aws.StringSlice(names[i:end])})
+		if err != nil {
+			return 0, err
+		}
+		for name, state := range s {
+			states[name] = state
+		}
+	}
+	if m.prefix != "" {
+		s, err := m.describeAlarms(&cloudwatch.DescribeAlarmsInput{AlarmNamePrefix: aws.String(m.prefix)})
+		if err != nil {
+			return 0, err
+		}
+		for name, state := range s {
+			states[name] = state
+		}
+	}
+	if len(states) < 1 {
+		if m.prefix != "" {
+			names = append(names, m.prefix+"*")
+		}
+		return 0, &NoDataError{query: strings.Join(names, ", ")}
+	}
+
+	n := 0
+	for _, state := range states {
+		if state == cloudwatch.StateValueAlarm {
+			n++
+		}
+	}
+
+	return float64(n), nil
+}
+
+func NewCloudWatchAlarmMetrics(config *CloudWatchAlarmConfig) (*CloudWatchAlarmMetrics, error) {
+	client := config.Client
+	if client == nil {
+		if config.Sess == nil {
+			return nil, errors.New("CloudWatchAlarmConfig.Sess must be set when CloudWatchAlarmConfig.Client is missing")
+		}
+		client = cloudwatch.New(config.Sess)
+	}
+
+	return &CloudWatchAlarmMetrics{
+		client:     client,
+		alarmNames: config.AlarmNames,
+		prefix:     config.AlarmNamePrefix,
+	}, nil
+}
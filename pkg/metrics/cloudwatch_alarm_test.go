@@ -0,0 +1,102 @@
+package metrics_test
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/k-kinzal/progressived/pkg/metrics"
+	"strings"
+	"testing"
+)
+
+type fakeCloudWatchAlarmClient struct {
+	states map[string]string
+	// composites are the names of the composite alarms in states
+	composites map[string]bool
+}
+
+// DescribeAlarms returns one alarm per page to exercise the pagination.
+func (c *fakeCloudWatchAlarmClient) DescribeAlarms(input *cloudwatch.DescribeAlarmsInput) (*cloudwatch.DescribeAlarmsOutput, error) {
+	var names []string
+	for name := range c.states {
+		match := false
+		for _, n := range input.AlarmNames {
+			match = match || aws.StringValue(n) == name
+		}
+		if input.AlarmNamePrefix != nil && strings.HasPrefix(name, aws.StringValue(input.AlarmNamePrefix)) {
+			match = true
+		}
+		if match && name > aws.StringValue(input.NextToken) {
+			names = append(names, name)
+		}
+	}
+	out := &cloudwatch.DescribeAlarmsOutput{}
+	if len(names) == 0 {
+		return out, nil
+	}
+	name := names[0]
+	for _, n := range names {
+		if n < name {
+			name = n
+		}
+	}
+	if c.composites[name] {
+		out.CompositeAlarms = []*cloudwatch.CompositeAlarm{{AlarmName: aws.String(name), StateValue: aws.String(c.states[name])}}
+	} else {
+		out.MetricAlarms = []*cloudwatch.MetricAlarm{{AlarmName: aws.String(name), StateValue: aws.String(c.states[name])}}
+	}
+	if len(names) > 1 {
+		out.NextToken = aws.String(name)
+	}
+	return out, nil
+}
+
+func TestCloudWatchAlarmMetrics_GetMetric(t *testing.T) {
+	client := &fakeCloudWatchAlarmClient{
+		states: map[string]string{
+			"green-5xx":      cloudwatch.StateValueAlarm,
+			"green-latency":  cloudwatch.StateValueOk,
+			"green-health":   cloudwatch.StateValueAlarm,
+			"blue-5xx":       cloudwatch.StateValueAlarm,
+			"green-insuffic": cloudwatch.StateValueInsufficientData,
+		},
+		composites: map[string]bool{"green-health": true},
+	}
+	m, err := metrics.NewCloudWatchAlarmMetrics(&metrics.CloudWatchAlarmConfig{
+		Client:          client,
+		AlarmNamePrefix: "green-",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := m.GetMetric("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 2 {
+		t.Errorf("expected 2 alarms in ALARM state, but got %f", v)
+	}
+	// the alarms in the query are added
+	v, err = m.GetMetric("blue-5xx, green-5xx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 3 {
+		t.Errorf("expected 3 alarms in ALARM state, but got %f", v)
+	}
+}
+
+func TestCloudWatchAlarmMetrics_GetMetric_NoData(t *testing.T) {
+	m, err := metrics.NewCloudWatchAlarmMetrics(&metrics.CloudWatchAlarmConfig{
+		Client:     &fakeCloudWatchAlarmClient{},
+		AlarmNames: []string{"missing"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetMetric(""); err == nil {
+		t.Error("expected an error because the alarm does not exist")
+	} else if _, ok := err.(*metrics.NoDataError); !ok {
+		t.Errorf("expected NoDataError, but got %v", err)
+	}
+}
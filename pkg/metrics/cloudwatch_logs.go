@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"strconv"
+	"time"
+)
+
+const (
+	CloudWatchLogsMetricsType = "cloudwatch-logs"
+
+	cloudWatchLogsDefaultPollInterval = time.Second
+	cloudWatchLogsDefaultTimeout      = time.Minute
+)
+
+type CloudWatchLogsConfig struct {
+	Sess *session.Session
+
+	Client CloudWatchLogsClient
+
+	LogGroupNames []string
+	// Field is the name of the field in the first result row that holds the value.
+	// Defaults to the first field of the row.
+	Field string
+
+	Period       time.Duration
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+type CloudWatchLogsClient interface {
+	StartQuery(input *cloudwatchlogs.StartQueryInput) (*cloudwatchlogs.StartQueryOutput, error)
+	GetQueryResults(input *cloudwatchlogs.GetQueryResultsInput) (*cloudwatchlogs.GetQueryResultsOutput, error)
+	StopQuery(input *cloudwatchlogs.StopQueryInput) (*cloudwatchlogs.StopQueryOutput, error)
+}
+
+// CloudWatchLogsMetrics runs a CloudWatch Logs Insights query over the collection period.
+type CloudWatchLogsMetrics struct {
+	client        CloudWatchLogsClient
+	logGroupNames []string
+	field         string
+	period        time.Duration
+	pollInterval  time.Duration
+	timeout       time.Duration
+}
+
+func (m *CloudWatchLogsMetrics) waitQueryResults(query string, queryId *string) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+	deadline := time.Now().Add(m.timeout)
+	for {
+		res, err := m.client.GetQueryResults(&cloudwatchlogs.GetQueryResultsInput{QueryId: queryId})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cloudwatch logs query results: %w", err)
+		}
+		switch aws.StringValue(res.Status) {
+		case cloudwatchlogs.QueryStatusComplete:
+			return res, nil
+		case cloudwatchlogs.QueryStatusFailed, cloudwatchlogs.QueryStatusCancelled:
+			return nil, fmt.Errorf("cloudwatch logs query `%s` is %s", aws.StringValue(queryId), aws.StringValue(res.Status))
+		// Timeout is returned by the service, but is not defined in this version of the SDK
+		case "Timeout":
+			return nil, &TimeoutError{query: query, timeout: m.timeout}
+		}
+
+		if time.Now().Add(m.pollInterval).After(deadline) {
+			// the query is no longer needed, so the error of stopping it is not important
+			m.client.StopQuery(&cloudwatchlogs.StopQueryInput{QueryId: queryId})
+			return nil, &TimeoutError{query: query, timeout: m.timeout}
+		}
+		time.Sleep(m.pollInterval)
+	}
+}
+
+func (m *CloudWatchLogsMetrics) GetMetric(query string) (float64, error) {
+	end := time.Now()
+	start := end.Add(-m.period)
+	out, err := m.client.StartQuery(&cloudwatchlogs.StartQueryInput{
+		LogGroupNames: aws.StringSlice(m.logGroupNames),
+		QueryString:   aws.String(query),
+		StartTime:     aws.Int64(start.Unix()),
+		EndTime:       aws.Int64(end.Unix()),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to start cloudwatch logs query: %w", err)
+	}
+
+	res, err := m.waitQueryResults(query, out.QueryId)
+	if err != nil {
+		return 0, err
+	}
+	if len(res.Results) < 1 {
+		return 0, &NoDataError{query: query}
+	}
+
+	for _, f := range res.Results[0] {
+		name := aws.StringValue(f.Field)
+		if name == "@ptr" || (m.field != "" && name != m.field) {
+			continue
+		}
+		v, err := strconv.ParseFloat(aws.StringValue(f.Value), 64)
+		if err != nil {
+			return 0, fmt.Errorf("field `%s` of cloudwatch logs query result is not a number: %w", name, err)
+		}
+		return v, nil
+	}
+	// a field missing from the row is a mistake in the query or the config rather than no data
+	if m.field != "" {
+		return 0, fmt.Errorf("field `%s` is not in the cloudwatch logs query result", m.field)
+	}
+
+	return 0, &NoDataError{query: query}
+}
+
+func NewCloudWatchLogsMetrics(config *CloudWatchLogsConfig) (*CloudWatchLogsMetrics, error) {
+	if len(config.LogGroupNames) == 0 {
+		return nil, errors.New("CloudWatchLogsConfig.LogGroupNames is missing")
+	}
+	pollInterval := config.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = cloudWatchLogsDefaultPollInterval
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = cloudWatchLogsDefaultTimeout
+	}
+
+	client := config.Client
+	if client == nil {
+		if config.Sess == nil {
+			return nil, errors.New("CloudWatchLogsConfig.Sess must be set when CloudWatchLogsConfig.Client is missing")
+		}
+		client = cloudwatchlogs.New(config.Sess)
+	}
+
+	return &CloudWatchLogsMetrics{
+		client:        client,
+		logGroupNames: config.LogGroupNames,
+		field:         config.Field,
+		period:        config.Period,
+		pollInterval:  pollInterval,
+		timeout:       timeout,
+	}, nil
+}
@@ -0,0 +1,100 @@
+package metrics_test
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/k-kinzal/progressived/pkg/metrics"
+	"testing"
+	"time"
+)
+
+type fakeCloudWatchLogsClient struct {
+	statuses []string
+	results  [][]*cloudwatchlogs.ResultField
+	polls    int
+	stopped  bool
+}
+
+func (c *fakeCloudWatchLogsClient) StartQuery(input *cloudwatchlogs.StartQueryInput) (*cloudwatchlogs.StartQueryOutput, error) {
+	return &cloudwatchlogs.StartQueryOutput{QueryId: aws.String("query")}, nil
+}
+
+func (c *fakeCloudWatchLogsClient) GetQueryResults(input *cloudwatchlogs.GetQueryResultsInput) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+	status := c.statuses[len(c.statuses)-1]
+	if c.polls < len(c.statuses) {
+		status = c.statuses[c.polls]
+	}
+	c.polls++
+	out := &cloudwatchlogs.GetQueryResultsOutput{Status: aws.String(status)}
+	if status == cloudwatchlogs.QueryStatusComplete {
+		out.Results = c.results
+	}
+	return out, nil
+}
+
+func (c *fakeCloudWatchLogsClient) StopQuery(input *cloudwatchlogs.StopQueryInput) (*cloudwatchlogs.StopQueryOutput, error) {
+	c.stopped = true
+	return &cloudwatchlogs.StopQueryOutput{}, nil
+}
+
+func newCloudWatchLogsMetrics(t *testing.T, client metrics.CloudWatchLogsClient, field string) *metrics.CloudWatchLogsMetrics {
+	m, err := metrics.NewCloudWatchLogsMetrics(&metrics.CloudWatchLogsConfig{
+		Client:        client,
+		LogGroupNames: []string{"/app/web"},
+		Field:         field,
+		Period:        5 * time.Minute,
+		PollInterval:  time.Millisecond,
+		Timeout:       50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestCloudWatchLogsMetrics_GetMetric(t *testing.T) {
+	client := &fakeCloudWatchLogsClient{
+		statuses: []string{cloudwatchlogs.QueryStatusScheduled, cloudwatchlogs.QueryStatusRunning, cloudwatchlogs.QueryStatusComplete},
+		results: [][]*cloudwatchlogs.ResultField{
+			{
+				{Field: aws.String("requests"), Value: aws.String("200")},
+				{Field: aws.String("errorRate"), Value: aws.String("1.5")},
+			},
+		},
+	}
+	v, err := newCloudWatchLogsMetrics(t, client, "errorRate").GetMetric("stats avg(error) * 100 as errorRate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 1.5 {
+		t.Errorf("expected 1.5, but got %f", v)
+	}
+	if client.polls != 3 {
+		t.Errorf("expected 3 polls, but got %d", client.polls)
+	}
+
+	client.polls = 0
+	_, err = newCloudWatchLogsMetrics(t, client, "latency").GetMetric("stats avg(error) * 100 as errorRate")
+	if err == nil {
+		t.Error("expected an error because the field is not in the result")
+	} else if _, ok := err.(*metrics.NoDataError); ok {
+		t.Errorf("expected an error other than NoDataError, but got %v", err)
+	}
+
+	client.results = nil
+	_, err = newCloudWatchLogsMetrics(t, client, "errorRate").GetMetric("stats avg(error) * 100 as errorRate")
+	if _, ok := err.(*metrics.NoDataError); !ok {
+		t.Errorf("expected NoDataError, but got %v", err)
+	}
+}
+
+func TestCloudWatchLogsMetrics_GetMetric_Timeout(t *testing.T) {
+	client := &fakeCloudWatchLogsClient{statuses: []string{cloudwatchlogs.QueryStatusRunning}}
+	_, err := newCloudWatchLogsMetrics(t, client, "").GetMetric("stats count(*)")
+	if e, ok := err.(*metrics.TimeoutError); !ok || !e.Temporary() {
+		t.Errorf("expected temporary TimeoutError, but got %v", err)
+	}
+	if !client.stopped {
+		t.Error("expected the query to be stopped")
+	}
+}
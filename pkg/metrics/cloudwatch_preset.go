@@ -0,0 +1,198 @@
+package metrics
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CloudWatchPreset is a named set of CloudWatch queries for a common AWS resource.
+type CloudWatchPreset struct {
+	Description string
+	// Parameters are the names of the parameters required by Queries.
+	Parameters []string
+	// Condition is used when no condition is specified.
+	Condition string
+	// Queries returns the queries for the parameters. The first query that returns data is evaluated.
+	Queries func(params map[string]string) []*CloudWatchQuery
+}
+
+// Expand validates the parameters and returns the queries of the preset.
+func (p *CloudWatchPreset) Expand(params map[string]string) ([]*CloudWatchQuery, error) {
+	for _, name := range p.Parameters {
+		if params[name] == "" {
+			return nil, fmt.Errorf("parameter `%s` is required", name)
+		}
+	}
+	return p.Queries(params), nil
+}
+
+var (
+	cloudWatchPresetsMu sync.RWMutex
+	cloudWatchPresets   = make(map[string]*CloudWatchPreset)
+)
+
+// RegisterCloudWatchPreset makes a preset available by the name.
+func RegisterCloudWatchPreset(name string, preset *CloudWatchPreset) error {
+	cloudWatchPresetsMu.Lock()
+	defer cloudWatchPresetsMu.Unlock()
+
+	if preset == nil || preset.Queries == nil {
+		return fmt.Errorf("preset `%s` must have queries", name)
+	}
+	if _, ok := cloudWatchPresets[name]; ok {
+		return fmt.Errorf("preset `%s` is already registered", name)
+	}
+	cloudWatchPresets[name] = preset
+
+	return nil
+}
+
+// LookupCloudWatchPreset returns the preset registered by the name.
+func LookupCloudWatchPreset(name string) (*CloudWatchPreset, error) {
+	cloudWatchPresetsMu.RLock()
+	defer cloudWatchPresetsMu.RUnlock()
+
+	preset, ok := cloudWatchPresets[name]
+	if !ok {
+		return nil, fmt.Errorf("preset `%s` is not registered. available presets are %s", name, strings.Join(cloudWatchPresetNames(), ", "))
+	}
+	return preset, nil
+}
+
+// CloudWatchPresetNames returns the sorted names of the registered presets.
+func CloudWatchPresetNames() []string {
+	cloudWatchPresetsMu.RLock()
+	defer cloudWatchPresetsMu.RUnlock()
+
+	return cloudWatchPresetNames()
+}
+
+func cloudWatchPresetNames() []string {
+	names := make([]string, 0, len(cloudWatchPresets))
+	for name := range cloudWatchPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// elbDimension returns the CloudWatch dimension value of a load balancer or target group.
+// It accepts either the ARN or the dimension value itself.
+func elbDimension(s string) string {
+	if !strings.HasPrefix(s, "arn:") {
+		return s
+	}
+	resource := s[strings.LastIndex(s, ":")+1:]
+	return strings.TrimPrefix(resource, "loadbalancer/")
+}
+
+func rateQueries(namespace string, errorMetric string, totalMetric string, dimensions map[string]string) []*CloudWatchQuery {
+	return []*CloudWatchQuery{
+		{
+			Id:         "rate",
+			Label:      "error rate (%)",
+			Expression: "IF(total > 0, 100 * FILL(errors, 0) / total, 0)",
+		},
+		{
+			Id:         "errors",
+			Namespace:  namespace,
+			MetricName: errorMetric,
+			Dimensions: dimensions,
+			Stat:       "Sum",
+			ReturnData: aws.Bool(false),
+		},
+		{
+			Id:         "total",
+			Namespace:  namespace,
+			MetricName: totalMetric,
+			Dimensions: dimensions,
+			Stat:       "Sum",
+			ReturnData: aws.Bool(false),
+		},
+	}
+}
+
+func init() {
+	builtins := map[string]*CloudWatchPreset{
+		"alb-5xx-rate": {
+			Description: "Percentage of target 5xx responses of an ALB target group",
+			Parameters:  []string{"loadBalancer", "targetGroup"},
+			Condition:   "x < 1",
+			Queries: func(params map[string]string) []*CloudWatchQuery {
+				return rateQueries("AWS/ApplicationELB", "HTTPCode_Target_5XX_Count", "RequestCount", map[string]string{
+					"LoadBalancer": elbDimension(params["loadBalancer"]),
+					"TargetGroup":  elbDimension(params["targetGroup"]),
+				})
+			},
+		},
+		"alb-latency-p99": {
+			Description: "p99 of the target response time of an ALB target group in seconds",
+			Parameters:  []string{"loadBalancer", "targetGroup"},
+			Condition:   "x < 1",
+			Queries: func(params map[string]string) []*CloudWatchQuery {
+				return []*CloudWatchQuery{
+					{
+						Id:         "latency",
+						Namespace:  "AWS/ApplicationELB",
+						MetricName: "TargetResponseTime",
+						Dimensions: map[string]string{
+							"LoadBalancer": elbDimension(params["loadBalancer"]),
+							"TargetGroup":  elbDimension(params["targetGroup"]),
+						},
+						Stat: "p99",
+					},
+				}
+			},
+		},
+		"lambda-error-rate": {
+			Description: "Percentage of failed invocations of a Lambda function",
+			Parameters:  []string{"functionName"},
+			Condition:   "x < 1",
+			Queries: func(params map[string]string) []*CloudWatchQuery {
+				dimensions := map[string]string{"FunctionName": params["functionName"]}
+				if q := params["qualifier"]; q != "" {
+					dimensions["Resource"] = fmt.Sprintf("%s:%s", params["functionName"], q)
+				}
+				return rateQueries("AWS/Lambda", "Errors", "Invocations", dimensions)
+			},
+		},
+		"apigateway-5xx-rate": {
+			Description: "Percentage of 5xx responses of a REST API stage",
+			Parameters:  []string{"apiName", "stage"},
+			Condition:   "x < 1",
+			Queries: func(params map[string]string) []*CloudWatchQuery {
+				return rateQueries("AWS/ApiGateway", "5XXError", "Count", map[string]string{
+					"ApiName": params["apiName"],
+					"Stage":   params["stage"],
+				})
+			},
+		},
+		"apigateway-latency-p99": {
+			Description: "p99 of the latency of a REST API stage in milliseconds",
+			Parameters:  []string{"apiName", "stage"},
+			Condition:   "x < 1000",
+			Queries: func(params map[string]string) []*CloudWatchQuery {
+				return []*CloudWatchQuery{
+					{
+						Id:         "latency",
+						Namespace:  "AWS/ApiGateway",
+						MetricName: "Latency",
+						Dimensions: map[string]string{
+							"ApiName": params["apiName"],
+							"Stage":   params["stage"],
+						},
+						Stat: "p99",
+					},
+				}
+			},
+		},
+	}
+	for name, preset := range builtins {
+		if err := RegisterCloudWatchPreset(name, preset); err != nil {
+			panic(err)
+		}
+	}
+}
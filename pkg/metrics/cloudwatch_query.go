@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	cloudWatchDefaultStat   = "Average"
+	cloudWatchDefaultPeriod = time.Minute
+)
+
+var cloudWatchQueryIdRegexp = regexp.MustCompile(`^[a-z][a-zA-Z0-9_]*$`)
+
+// CloudWatchQuery is a declarative form of cloudwatch.MetricDataQuery.
+// Either MetricName or Expression must be set. Expression is a metric math
+// expression that can reference the Id of other queries.
+type CloudWatchQuery struct {
+	Id         string
+	Label      string
+	Namespace  string
+	MetricName string
+	Dimensions map[string]string
+	Stat       string
+	Unit       string
+	Period     time.Duration
+	Expression string
+	ReturnData *bool
+}
+
+func (q *CloudWatchQuery) compile() (*cloudwatch.MetricDataQuery, error) {
+	if !cloudWatchQueryIdRegexp.MatchString(q.Id) {
+		return nil, fmt.Errorf("id `%s` must start with a lowercase letter and contain only letters, numbers and underscores", q.Id)
+	}
+	if q.Expression != "" && q.MetricName != "" {
+		return nil, fmt.Errorf("query `%s` cannot have both expression and metricName", q.Id)
+	}
+	if q.Expression == "" && q.MetricName == "" {
+		return nil, fmt.Errorf("query `%s` must have either expression or metricName", q.Id)
+	}
+
+	mdq := &cloudwatch.MetricDataQuery{
+		Id:         aws.String(q.Id),
+		ReturnData: q.ReturnData,
+	}
+	if q.Label != "" {
+		mdq.Label = aws.String(q.Label)
+	}
+	if q.Expression != "" {
+		mdq.Expression = aws.String(q.Expression)
+		return mdq, nil
+	}
+
+	if q.Namespace == "" {
+		return nil, fmt.Errorf("query `%s` must have namespace", q.Id)
+	}
+	period := q.Period
+	if period == 0 {
+		period = cloudWatchDefaultPeriod
+	}
+	if period%time.Second != 0 || period < time.Second {
+		return nil, fmt.Errorf("period of query `%s` must be a whole number of seconds", q.Id)
+	}
+	stat := q.Stat
+	if stat == "" {
+		stat = cloudWatchDefaultStat
+	}
+
+	names := make([]string, 0, len(q.Dimensions))
+	for name := range q.Dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	dimensions := make([]*cloudwatch.Dimension, 0, len(names))
+	for _, name := range names {
+		dimensions = append(dimensions, &cloudwatch.Dimension{
+			Name:  aws.String(name),
+			Value: aws.String(q.Dimensions[name]),
+		})
+	}
+
+	mdq.MetricStat = &cloudwatch.MetricStat{
+		Metric: &cloudwatch.Metric{
+			Namespace:  aws.String(q.Namespace),
+			MetricName: aws.String(q.MetricName),
+			Dimensions: dimensions,
+		},
+		Period: aws.Int64(int64(period / time.Second)),
+		Stat:   aws.String(stat),
+	}
+	if q.Unit != "" {
+		mdq.MetricStat.Unit = aws.String(q.Unit)
+	}
+
+	return mdq, nil
+}
+
+// CompileCloudWatchQueries compiles the declarative queries into cloudwatch.MetricDataQuery values.
+func CompileCloudWatchQueries(queries []*CloudWatchQuery) ([]*cloudwatch.MetricDataQuery, error) {
+	if len(queries) == 0 {
+		return nil, errors.New("at least one query is required")
+	}
+
+	ids := make(map[string]bool, len(queries))
+	compiled := make([]*cloudwatch.MetricDataQuery, 0, len(queries))
+	for _, q := range queries {
+		if ids[q.Id] {
+			return nil, fmt.Errorf("id `%s` is duplicated", q.Id)
+		}
+		ids[q.Id] = true
+
+		mdq, err := q.compile()
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, mdq)
+	}
+
+	return compiled, nil
+}
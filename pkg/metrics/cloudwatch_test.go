@@ -0,0 +1,190 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/k-kinzal/progressived/pkg/metrics"
+)
+
+type fakeCloudWatchClient struct {
+	pages []*cloudwatch.GetMetricDataOutput
+	calls int
+}
+
+func (c *fakeCloudWatchClient) GetMetricData(input *cloudwatch.GetMetricDataInput) (*cloudwatch.GetMetricDataOutput, error) {
+	page := c.pages[c.calls]
+	c.calls++
+	return page, nil
+}
+
+func newFakeCloudWatchClient() *fakeCloudWatchClient {
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &fakeCloudWatchClient{
+		pages: []*cloudwatch.GetMetricDataOutput{
+			{
+				MetricDataResults: []*cloudwatch.MetricDataResult{
+					{Id: aws.String("m1"), Values: aws.Float64Slice([]float64{100, 200}), Timestamps: aws.TimeSlice([]time.Time{t0, t0.Add(time.Minute)})},
+					{Id: aws.String("e1"), Values: aws.Float64Slice([]float64{1, 3}), Timestamps: aws.TimeSlice([]time.Time{t0, t0.Add(time.Minute)})},
+				},
+				NextToken: aws.String("next"),
+			},
+			{
+				MetricDataResults: []*cloudwatch.MetricDataResult{
+					{Id: aws.String("e1"), Values: aws.Float64Slice([]float64{2}), Timestamps: aws.TimeSlice([]time.Time{t0.Add(2 * time.Minute)})},
+				},
+			},
+		},
+	}
+}
+
+const cloudWatchQuery = `[{"Id":"m1","ReturnData":false,"MetricStat":{"Metric":{"Namespace":"AWS/ApplicationELB","MetricName":"RequestCount"},"Period":60,"Stat":"Sum"}},{"Id":"e1","Expression":"m1 * 0.01"}]`
+
+func TestCloudWatchMetrics_GetMetric(t *testing.T) {
+	cases := []struct {
+		reduction string
+		resultId  string
+		expected  float64
+	}{
+		{"latest", "", 2},
+		{"max", "", 3},
+		{"sum", "", 6},
+		{"average", "m1", 150},
+		{"p50", "", 2},
+	}
+	for _, c := range cases {
+		reduction, err := metrics.ParseReduction(c.reduction)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := newFakeCloudWatchClient()
+		m, err := metrics.NewCloudWatchMetrics(&metrics.CloudWatchConfig{
+			Client:    client,
+			Period:    5 * time.Minute,
+			Reduction: reduction,
+			ResultId:  c.resultId,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := m.GetMetric(cloudWatchQuery)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != c.expected {
+			t.Errorf("%s of `%s`: expected %f, but got %f", c.reduction, c.resultId, c.expected, v)
+		}
+		if client.calls != 2 {
+			t.Errorf("expected 2 pages to be fetched, but got %d", client.calls)
+		}
+	}
+}
+
+func TestCloudWatchMetrics_GetMetricSeries(t *testing.T) {
+	m, err := metrics.NewCloudWatchMetrics(&metrics.CloudWatchConfig{
+		Client: newFakeCloudWatchClient(),
+		Period: 5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := m.GetMetricSeries(cloudWatchQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []float64{1, 3, 2}
+	if len(values) != len(expected) {
+		t.Fatalf("expected %v, but got %v", expected, values)
+	}
+	for i := range expected {
+		if values[i] != expected[i] {
+			t.Fatalf("expected %v, but got %v", expected, values)
+		}
+	}
+}
+
+func TestParseReduction(t *testing.T) {
+	for _, s := range []string{"", "latest", "avg", "p99", "p99.9"} {
+		if _, err := metrics.ParseReduction(s); err != nil {
+			t.Errorf("unexpected error for `%s`: %v", s, err)
+		}
+	}
+	for _, s := range []string{"median", "p101", "px"} {
+		if _, err := metrics.ParseReduction(s); err == nil {
+			t.Errorf("expected error for `%s`", s)
+		}
+	}
+}
+
+func TestCompileCloudWatchQueries(t *testing.T) {
+	queries, err := metrics.CompileCloudWatchQueries([]*metrics.CloudWatchQuery{
+		{
+			Id:         "errors",
+			Namespace:  "AWS/ApplicationELB",
+			MetricName: "HTTPCode_Target_5XX_Count",
+			Dimensions: map[string]string{"TargetGroup": "targetgroup/green/1234", "LoadBalancer": "app/edge/5678"},
+			Stat:       "Sum",
+			ReturnData: aws.Bool(false),
+		},
+		{
+			Id:         "rate",
+			Expression: "errors * 100",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat := queries[0].MetricStat
+	if aws.Int64Value(stat.Period) != 60 || aws.StringValue(stat.Stat) != "Sum" {
+		t.Errorf("unexpected metric stat: %v", stat)
+	}
+	if aws.StringValue(stat.Metric.Dimensions[0].Name) != "LoadBalancer" {
+		t.Errorf("dimensions must be sorted by name: %v", stat.Metric.Dimensions)
+	}
+	if aws.StringValue(queries[1].Expression) != "errors * 100" {
+		t.Errorf("unexpected expression: %v", queries[1])
+	}
+
+	invalid := [][]*metrics.CloudWatchQuery{
+		{{Id: "Upper", Expression: "1"}},
+		{{Id: "a", Expression: "1"}, {Id: "a", Expression: "2"}},
+		{{Id: "a"}},
+		{{Id: "a", MetricName: "RequestCount"}},
+	}
+	for _, q := range invalid {
+		if _, err := metrics.CompileCloudWatchQueries(q); err == nil {
+			t.Errorf("expected error for %v", q)
+		}
+	}
+}
+
+func TestCloudWatchPreset_Expand(t *testing.T) {
+	preset, err := metrics.LookupCloudWatchPreset("alb-5xx-rate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := preset.Expand(map[string]string{"loadBalancer": "app/edge/5678"}); err == nil {
+		t.Error("expected error for missing targetGroup")
+	}
+	queries, err := preset.Expand(map[string]string{
+		"loadBalancer": "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/edge/5678",
+		"targetGroup":  "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/green/1234",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := metrics.CompileCloudWatchQueries(queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dimensions := compiled[1].MetricStat.Metric.Dimensions
+	if aws.StringValue(dimensions[0].Value) != "app/edge/5678" || aws.StringValue(dimensions[1].Value) != "targetgroup/green/1234" {
+		t.Errorf("unexpected dimensions: %v", dimensions)
+	}
+
+	if err := metrics.RegisterCloudWatchPreset("alb-5xx-rate", preset); err == nil {
+		t.Error("expected error for duplicated preset")
+	}
+}
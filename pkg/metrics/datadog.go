@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DatadogMetricsType = "datadog"
+
+	DatadogDefaultSite = "datadoghq.com"
+
+	datadogDefaultTimeout = 30 * time.Second
+)
+
+type DatadogConfig struct {
+	Client *http.Client
+
+	// Site is the Datadog site such as `datadoghq.com` or `datadoghq.eu`.
+	// Defaults to the DD_SITE environment variable or `datadoghq.com`.
+	Site string
+	// BaseURL overrides the URL derived from Site, e.g. for a local stand-in.
+	BaseURL string
+	// APIKey defaults to the DD_API_KEY environment variable.
+	APIKey string
+	// ApplicationKey defaults to the DD_APP_KEY environment variable.
+	ApplicationKey string
+
+	Period    time.Duration
+	Reduction *Reduction
+}
+
+type DatadogMetrics struct {
+	client         *http.Client
+	baseURL        string
+	apiKey         string
+	applicationKey string
+	period         time.Duration
+	reduction      *Reduction
+}
+
+type datadogQueryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Series []struct {
+		Expression string        `json:"expression"`
+		Pointlist  [][2]*float64 `json:"pointlist"`
+	} `json:"series"`
+}
+
+// GetMetricSeries returns the points of the series ordered from the oldest to the latest.
+func (m *DatadogMetrics) GetMetricSeries(query string) ([]float64, error) {
+	end := time.Now()
+	start := end.Add(-m.period)
+	params := url.Values{}
+	params.Set("from", strconv.FormatInt(start.Unix(), 10))
+	params.Set("to", strconv.FormatInt(end.Unix(), 10))
+	params.Set("query", query)
+
+	req, err := http.NewRequest(http.MethodGet, m.baseURL+"/api/v1/query?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("DD-API-KEY", m.apiKey)
+	req.Header.Set("DD-APPLICATION-KEY", m.applicationKey)
+
+	res, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get datadog metrics: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get datadog metrics: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get datadog metrics: %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+
+	var out datadogQueryResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("unmarshal datadog response failed: %w", err)
+	}
+	if out.Status == "error" {
+		return nil, fmt.Errorf("failed to get datadog metrics: %s", out.Error)
+	}
+	if len(out.Series) > 1 {
+		return nil, fmt.Errorf("datadog query returned %d series, but a single series is required. aggregate the query, e.g. `sum:...{...}`", len(out.Series))
+	}
+
+	var values []float64
+	for _, s := range out.Series {
+		for _, point := range s.Pointlist {
+			if point[1] == nil {
+				continue
+			}
+			values = append(values, *point[1])
+		}
+	}
+	if len(values) < 1 {
+		return nil, &NoDataError{query: query}
+	}
+
+	return values, nil
+}
+
+func (m *DatadogMetrics) GetMetric(query string) (float64, error) {
+	values, err := m.GetMetricSeries(query)
+	if err != nil {
+		return 0, err
+	}
+
+	return m.reduction.Reduce(values)
+}
+
+func NewDatadogMetrics(config *DatadogConfig) (*DatadogMetrics, error) {
+	apiKey := config.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("DD_API_KEY")
+	}
+	if apiKey == "" {
+		return nil, errors.New("DatadogConfig.APIKey or DD_API_KEY must be set")
+	}
+	applicationKey := config.ApplicationKey
+	if applicationKey == "" {
+		applicationKey = os.Getenv("DD_APP_KEY")
+	}
+	if applicationKey == "" {
+		return nil, errors.New("DatadogConfig.ApplicationKey or DD_APP_KEY must be set")
+	}
+
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		site := config.Site
+		if site == "" {
+			site = os.Getenv("DD_SITE")
+		}
+		if site == "" {
+			site = DatadogDefaultSite
+		}
+		baseURL = fmt.Sprintf("https://api.%s", site)
+	}
+
+	reduction := config.Reduction
+	if reduction == nil {
+		reduction = &Reduction{name: ReductionLatest}
+	}
+
+	client := config.Client
+	if client == nil {
+		client = &http.Client{Timeout: datadogDefaultTimeout}
+	}
+
+	return &DatadogMetrics{
+		client:         client,
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		apiKey:         apiKey,
+		applicationKey: applicationKey,
+		period:         config.Period,
+		reduction:      reduction,
+	}, nil
+}
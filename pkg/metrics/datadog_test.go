@@ -0,0 +1,91 @@
+package metrics_test
+
+import (
+	"github.com/k-kinzal/progressived/pkg/metrics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newDatadogStandIn(t *testing.T, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			t.Errorf("unexpected path `%s`", r.URL.Path)
+		}
+		if r.Header.Get("DD-API-KEY") != "api" || r.Header.Get("DD-APPLICATION-KEY") != "app" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Query().Get("query") == "" || r.URL.Query().Get("from") == "" || r.URL.Query().Get("to") == "" {
+			t.Errorf("unexpected query `%s`", r.URL.RawQuery)
+		}
+		w.Write([]byte(body))
+	}))
+}
+
+func newDatadogMetrics(t *testing.T, baseURL string, reduction string) *metrics.DatadogMetrics {
+	r, err := metrics.ParseReduction(reduction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := metrics.NewDatadogMetrics(&metrics.DatadogConfig{
+		BaseURL:        baseURL,
+		APIKey:         "api",
+		ApplicationKey: "app",
+		Period:         5 * time.Minute,
+		Reduction:      r,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestDatadogMetrics_GetMetric(t *testing.T) {
+	server := newDatadogStandIn(t, `{"status":"ok","series":[{"expression":"sum:trace.http.request.errors{service:web}","pointlist":[[1600000000000,1.0],[1600000060000,4.0],[1600000120000,3.0],[1600000180000,null]]}]}`)
+	defer server.Close()
+
+	v, err := newDatadogMetrics(t, server.URL, "max").GetMetric("sum:trace.http.request.errors{service:web}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 4 {
+		t.Errorf("expected 4, but got %f", v)
+	}
+	// the trailing null point is skipped
+	v, err = newDatadogMetrics(t, server.URL, "latest").GetMetric("sum:trace.http.request.errors{service:web}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 3 {
+		t.Errorf("expected 3, but got %f", v)
+	}
+}
+
+func TestDatadogMetrics_GetMetric_NoData(t *testing.T) {
+	server := newDatadogStandIn(t, `{"status":"ok","series":[]}`)
+	defer server.Close()
+
+	_, err := newDatadogMetrics(t, server.URL, "latest").GetMetric("sum:trace.http.request.errors{service:web}")
+	if _, ok := err.(*metrics.NoDataError); !ok {
+		t.Errorf("expected NoDataError, but got %v", err)
+	}
+}
+
+func TestDatadogMetrics_GetMetric_Forbidden(t *testing.T) {
+	server := newDatadogStandIn(t, `{}`)
+	defer server.Close()
+
+	m, err := metrics.NewDatadogMetrics(&metrics.DatadogConfig{
+		BaseURL:        server.URL,
+		APIKey:         "invalid",
+		ApplicationKey: "invalid",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetMetric("avg:system.load.1{*}"); err == nil {
+		t.Error("expected error for invalid keys")
+	}
+}
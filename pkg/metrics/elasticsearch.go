@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ElasticsearchMetricsType = "elasticsearch"
+
+	elasticsearchDefaultTimeout = 30 * time.Second
+)
+
+type ElasticsearchConfig struct {
+	Client *http.Client
+
+	// URL is the endpoint of the Elasticsearch or OpenSearch cluster.
+	URL string
+	// Index is the index, alias or pattern to search, e.g. `access-*`.
+	Index string
+	// ValuePath is the path of the value in the response, e.g. `aggregations.error_rate.value`.
+	ValuePath string
+
+	Username string
+	Password string
+	// APIKey is the base64 encoded API key sent in the Authorization header.
+	APIKey string
+
+	TLS     *TLSConfig
+	Timeout time.Duration
+}
+
+// ElasticsearchMetrics posts the query as the body of a search request and extracts the value from the response.
+type ElasticsearchMetrics struct {
+	client    *http.Client
+	searchURL string
+	valuePath string
+	username  string
+	password  string
+	apiKey    string
+}
+
+func (m *ElasticsearchMetrics) GetMetric(query string) (float64, error) {
+	req, err := http.NewRequest(http.MethodPost, m.searchURL, bytes.NewBufferString(query))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+m.apiKey)
+	} else if m.username != "" {
+		req.SetBasicAuth(m.username, m.password)
+	}
+
+	res, err := m.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search elasticsearch: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search elasticsearch: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to search elasticsearch: %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return 0, fmt.Errorf("unmarshal elasticsearch response failed: %w", err)
+	}
+
+	// hits.total is an object since Elasticsearch 7 and a number before that
+	total, err := lookupJSONPath(doc, "hits.total.value")
+	if err != nil {
+		return 0, err
+	}
+	if total == nil {
+		if total, err = lookupJSONPath(doc, "hits.total"); err != nil {
+			return 0, err
+		}
+	}
+	if n, err := jsonNumber(total); err == nil && n == 0 {
+		return 0, &NoDataError{query: query}
+	}
+
+	v, err := lookupJSONPath(doc, m.valuePath)
+	if err != nil {
+		return 0, err
+	}
+	if v == nil {
+		return 0, &NoDataError{query: query}
+	}
+	value, err := jsonNumber(v)
+	if err != nil {
+		return 0, fmt.Errorf("`%s` in elasticsearch response: %w", m.valuePath, err)
+	}
+
+	return value, nil
+}
+
+func NewElasticsearchMetrics(config *ElasticsearchConfig) (*ElasticsearchMetrics, error) {
+	if config.URL == "" {
+		return nil, errors.New("ElasticsearchConfig.URL is missing")
+	}
+	if config.ValuePath == "" {
+		return nil, errors.New("ElasticsearchConfig.ValuePath is missing")
+	}
+	if _, err := parseJSONPath(config.ValuePath); err != nil {
+		return nil, fmt.Errorf("ElasticsearchConfig.ValuePath: %w", err)
+	}
+
+	searchURL := strings.TrimSuffix(config.URL, "/")
+	if config.Index != "" {
+		searchURL += "/" + url.PathEscape(config.Index)
+	}
+	searchURL += "/_search"
+
+	client := config.Client
+	if client == nil {
+		timeout := config.Timeout
+		if timeout <= 0 {
+			timeout = elasticsearchDefaultTimeout
+		}
+		c, err := newHTTPClient(config.TLS, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("ElasticsearchConfig.TLS: %w", err)
+		}
+		client = c
+	}
+
+	return &ElasticsearchMetrics{
+		client:    client,
+		searchURL: searchURL,
+		valuePath: config.ValuePath,
+		username:  config.Username,
+		password:  config.Password,
+		apiKey:    config.APIKey,
+	}, nil
+}
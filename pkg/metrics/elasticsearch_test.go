@@ -0,0 +1,63 @@
+package metrics_test
+
+import (
+	"github.com/k-kinzal/progressived/pkg/metrics"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestElasticsearchMetrics_GetMetric(t *testing.T) {
+	cases := []struct {
+		response  string
+		valuePath string
+		expected  float64
+		noData    bool
+	}{
+		{`{"hits":{"total":{"value":120,"relation":"eq"}},"aggregations":{"error_rate":{"value":0.25}}}`, "aggregations.error_rate.value", 0.25, false},
+		{`{"hits":{"total":120},"aggregations":{"codes":{"buckets":[{"doc_count":100},{"doc_count":20}]}}}`, "$.aggregations.codes.buckets[-1].doc_count", 20, false},
+		{`{"hits":{"total":{"value":0,"relation":"eq"}},"aggregations":{"error_rate":{"value":null}}}`, "aggregations.error_rate.value", 0, true},
+	}
+	for _, c := range cases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.URL.Path != "/access-%2A/_search" && r.URL.Path != "/access-*/_search" {
+				t.Errorf("unexpected request `%s %s`", r.Method, r.URL.Path)
+			}
+			if user, pass, ok := r.BasicAuth(); !ok || user != "elastic" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if b, _ := ioutil.ReadAll(r.Body); string(b) != `{"size":0}` {
+				t.Errorf("unexpected body `%s`", b)
+			}
+			w.Write([]byte(c.response))
+		}))
+
+		m, err := metrics.NewElasticsearchMetrics(&metrics.ElasticsearchConfig{
+			URL:       server.URL,
+			Index:     "access-*",
+			ValuePath: c.valuePath,
+			Username:  "elastic",
+			Password:  "secret",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := m.GetMetric(`{"size":0}`)
+		server.Close()
+
+		if c.noData {
+			if _, ok := err.(*metrics.NoDataError); !ok {
+				t.Errorf("expected NoDataError, but got %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != c.expected {
+			t.Errorf("expected %f, but got %f", c.expected, v)
+		}
+	}
+}
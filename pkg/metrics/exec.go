@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ExecMetricsType = "exec"
+
+	// ExecQueryArgument appends the query to the arguments of the command.
+	ExecQueryArgument = "argument"
+	// ExecQueryStdin writes the query to the standard input of the command.
+	ExecQueryStdin = "stdin"
+	// ExecQueryEnvironment sets the query to the environment variable of the command.
+	ExecQueryEnvironment = "environment"
+
+	ExecDefaultQueryEnv = "PROGRESSIVED_QUERY"
+
+	execDefaultTimeout = 30 * time.Second
+)
+
+type ExecConfig struct {
+	Command string
+	Args    []string
+	// QueryMode is how the query is passed to the command. Defaults to ExecQueryArgument.
+	QueryMode string
+	// QueryEnv is the name of the environment variable for ExecQueryEnvironment.
+	QueryEnv string
+	// NoDataExitCode is the exit code that means there was no data. 0 disables it.
+	NoDataExitCode int
+
+	Timeout time.Duration
+}
+
+// ExecMetrics runs the command and parses the number in the last line of the standard output.
+type ExecMetrics struct {
+	command        string
+	args           []string
+	queryMode      string
+	queryEnv       string
+	noDataExitCode int
+	timeout        time.Duration
+}
+
+func (m *ExecMetrics) GetMetric(query string) (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	args := append([]string{}, m.args...)
+	if m.queryMode == ExecQueryArgument {
+		args = append(args, query)
+	}
+	cmd := exec.Command(m.command, args...)
+	// the command may start children that keep the output open, so the whole group is killed on the timeout
+	setProcessGroup(cmd)
+	switch m.queryMode {
+	case ExecQueryStdin:
+		cmd.Stdin = strings.NewReader(query)
+	case ExecQueryEnvironment:
+		cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", m.queryEnv, query))
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to run `%s`: %w", m.command, err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		<-done
+		return 0, &TimeoutError{query: query, timeout: m.timeout}
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && m.noDataExitCode != 0 && exitErr.ExitCode() == m.noDataExitCode {
+			return 0, &NoDataError{query: query}
+		}
+		return 0, fmt.Errorf("failed to run `%s`: %w: %s", m.command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	if last == "" {
+		return 0, &NoDataError{query: query}
+	}
+	value, err := strconv.ParseFloat(last, 64)
+	if err != nil {
+		return 0, fmt.Errorf("output of `%s` is not a number: %w", m.command, err)
+	}
+
+	return value, nil
+}
+
+func NewExecMetrics(config *ExecConfig) (*ExecMetrics, error) {
+	if config.Command == "" {
+		return nil, errors.New("ExecConfig.Command is missing")
+	}
+	queryMode := config.QueryMode
+	if queryMode == "" {
+		queryMode = ExecQueryArgument
+	}
+	switch queryMode {
+	case ExecQueryArgument, ExecQueryStdin, ExecQueryEnvironment:
+	default:
+		return nil, fmt.Errorf("ExecConfig.QueryMode can be either \"%s\", \"%s\", \"%s\"", ExecQueryArgument, ExecQueryStdin, ExecQueryEnvironment)
+	}
+	queryEnv := config.QueryEnv
+	if queryEnv == "" {
+		queryEnv = ExecDefaultQueryEnv
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = execDefaultTimeout
+	}
+
+	return &ExecMetrics{
+		command:        config.Command,
+		args:           config.Args,
+		queryMode:      queryMode,
+		queryEnv:       queryEnv,
+		noDataExitCode: config.NoDataExitCode,
+		timeout:        timeout,
+	}, nil
+}
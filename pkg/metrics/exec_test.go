@@ -0,0 +1,73 @@
+package metrics_test
+
+import (
+	"github.com/k-kinzal/progressived/pkg/metrics"
+	"testing"
+	"time"
+)
+
+func TestExecMetrics_GetMetric(t *testing.T) {
+	cases := []struct {
+		config   metrics.ExecConfig
+		expected float64
+	}{
+		{metrics.ExecConfig{Args: []string{"-c", `echo checking; echo "$1"`, "sh"}}, 1.5},
+		{metrics.ExecConfig{Args: []string{"-c", "cat"}, QueryMode: metrics.ExecQueryStdin}, 1.5},
+		{metrics.ExecConfig{Args: []string{"-c", `echo "$PROGRESSIVED_QUERY"`}, QueryMode: metrics.ExecQueryEnvironment}, 1.5},
+	}
+	for _, c := range cases {
+		c.config.Command = "sh"
+		m, err := metrics.NewExecMetrics(&c.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := m.GetMetric("1.5")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != c.expected {
+			t.Errorf("%s: expected %f, but got %f", c.config.QueryMode, c.expected, v)
+		}
+	}
+}
+
+func TestExecMetrics_GetMetric_NoData(t *testing.T) {
+	m, err := metrics.NewExecMetrics(&metrics.ExecConfig{Command: "sh", Args: []string{"-c", "exit 3"}, NoDataExitCode: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetMetric(""); err == nil {
+		t.Error("expected NoDataError")
+	} else if _, ok := err.(*metrics.NoDataError); !ok {
+		t.Errorf("expected NoDataError, but got %v", err)
+	}
+}
+
+func TestExecMetrics_GetMetric_Timeout(t *testing.T) {
+	m, err := metrics.NewExecMetrics(&metrics.ExecConfig{Command: "sleep", Args: []string{"5"}, QueryMode: metrics.ExecQueryStdin, Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetMetric(""); err == nil {
+		t.Error("expected TimeoutError")
+	} else if _, ok := err.(*metrics.TimeoutError); !ok {
+		t.Errorf("expected TimeoutError, but got %v", err)
+	}
+}
+
+func TestExecMetrics_GetMetric_TimeoutChild(t *testing.T) {
+	// the child of the shell keeps the standard output open after the shell is killed
+	m, err := metrics.NewExecMetrics(&metrics.ExecConfig{Command: "sh", Args: []string{"-c", "sleep 60; echo 1"}, QueryMode: metrics.ExecQueryStdin, Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := m.GetMetric(""); err == nil {
+		t.Error("expected TimeoutError")
+	} else if _, ok := err.(*metrics.TimeoutError); !ok {
+		t.Errorf("expected TimeoutError, but got %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("expected the command to be killed on the timeout, but it took %s", d)
+	}
+}
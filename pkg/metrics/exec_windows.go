@@ -0,0 +1,12 @@
+package metrics
+
+import (
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills only the command, because Windows has no process group to signal.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
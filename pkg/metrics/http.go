@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	HTTPMetricsType = "http"
+
+	httpDefaultTimeout       = 30 * time.Second
+	httpDefaultRetryInterval = time.Second
+)
+
+type HTTPConfig struct {
+	Client *http.Client
+
+	// ValuePath is a JSONPath or jq-style path of the value in the response body, e.g. `$.health.score` or `.health.score`.
+	ValuePath string
+	// StatusCodes are the status codes treated as success. Defaults to any 2xx.
+	StatusCodes []int
+	// NoDataStatusCodes are the status codes treated as no data, e.g. 404.
+	NoDataStatusCodes []int
+
+	Retries       int
+	RetryInterval time.Duration
+
+	TLS     *TLSConfig
+	Timeout time.Duration
+}
+
+// HTTPRequest is the request rendered from the query as YAML or JSON.
+// A query that is a single URL is sent as a GET request.
+type HTTPRequest struct {
+	URL     string            `yaml:"url"`
+	Method  string            `yaml:"method"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+}
+
+// HTTPMetrics sends the request rendered from the query and extracts the value from the JSON response.
+type HTTPMetrics struct {
+	client            *http.Client
+	valuePath         string
+	statusCodes       []int
+	noDataStatusCodes []int
+	retries           int
+	retryInterval     time.Duration
+}
+
+func parseHTTPRequest(query string) (*HTTPRequest, error) {
+	q := strings.TrimSpace(query)
+	if !strings.ContainsAny(q, "\n ") && (strings.HasPrefix(q, "http://") || strings.HasPrefix(q, "https://")) {
+		return &HTTPRequest{URL: q, Method: http.MethodGet}, nil
+	}
+
+	var req HTTPRequest
+	if err := yaml.UnmarshalStrict([]byte(q), &req); err != nil {
+		return nil, fmt.Errorf("unmarshal to http request failed: %w", err)
+	}
+	if req.URL == "" {
+		return nil, errors.New("url of http request is missing")
+	}
+	if req.Method == "" {
+		req.Method = http.MethodGet
+		if req.Body != "" {
+			req.Method = http.MethodPost
+		}
+	}
+	return &req, nil
+}
+
+func containsStatusCode(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retryableStatusCode reports whether the request can succeed by sending it again.
+func retryableStatusCode(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+func (m *HTTPMetrics) do(r *HTTPRequest) (int, []byte, error) {
+	req, err := http.NewRequest(strings.ToUpper(r.Method), r.URL, bytes.NewBufferString(r.Body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range r.Headers {
+		req.Header.Set(name, value)
+	}
+
+	res, err := m.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, body, nil
+}
+
+func (m *HTTPMetrics) GetMetric(query string) (float64, error) {
+	r, err := parseHTTPRequest(query)
+	if err != nil {
+		return 0, err
+	}
+
+	var status int
+	var body []byte
+	for i := 0; ; i++ {
+		status, body, err = m.do(r)
+		if err == nil && !retryableStatusCode(status) {
+			break
+		}
+		if i >= m.retries {
+			break
+		}
+		time.Sleep(m.retryInterval)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to request `%s`: %w", r.URL, err)
+	}
+
+	if containsStatusCode(m.noDataStatusCodes, status) {
+		return 0, &NoDataError{query: r.URL}
+	}
+	ok := status >= 200 && status < 300
+	if len(m.statusCodes) > 0 {
+		ok = containsStatusCode(m.statusCodes, status)
+	}
+	if !ok {
+		return 0, fmt.Errorf("failed to request `%s`: unexpected status code %d: %s", r.URL, status, strings.TrimSpace(string(body)))
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return 0, fmt.Errorf("unmarshal response of `%s` failed: %w", r.URL, err)
+	}
+	v, err := lookupJSONPath(doc, m.valuePath)
+	if err != nil {
+		return 0, err
+	}
+	if v == nil {
+		return 0, &NoDataError{query: r.URL}
+	}
+	value, err := jsonNumber(v)
+	if err != nil {
+		return 0, fmt.Errorf("`%s` in response of `%s`: %w", m.valuePath, r.URL, err)
+	}
+
+	return value, nil
+}
+
+func NewHTTPMetrics(config *HTTPConfig) (*HTTPMetrics, error) {
+	if config.ValuePath == "" {
+		return nil, errors.New("HTTPConfig.ValuePath is missing")
+	}
+	if _, err := parseJSONPath(config.ValuePath); err != nil {
+		return nil, fmt.Errorf("HTTPConfig.ValuePath: %w", err)
+	}
+	if config.Retries < 0 {
+		return nil, errors.New("HTTPConfig.Retries must be greater than or equal to 0")
+	}
+	retryInterval := config.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = httpDefaultRetryInterval
+	}
+
+	client := config.Client
+	if client == nil {
+		timeout := config.Timeout
+		if timeout <= 0 {
+			timeout = httpDefaultTimeout
+		}
+		c, err := newHTTPClient(config.TLS, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("HTTPConfig.TLS: %w", err)
+		}
+		client = c
+	}
+
+	return &HTTPMetrics{
+		client:            client,
+		valuePath:         config.ValuePath,
+		statusCodes:       config.StatusCodes,
+		noDataStatusCodes: config.NoDataStatusCodes,
+		retries:           config.Retries,
+		retryInterval:     retryInterval,
+	}, nil
+}
@@ -0,0 +1,103 @@
+package metrics_test
+
+import (
+	"fmt"
+	"github.com/k-kinzal/progressived/pkg/metrics"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPMetrics_GetMetric_Retry(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first two requests fail as if the service was overloaded
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"health":{"checks":[{"score":0.25},{"score":0.75}]}}`))
+	}))
+	defer server.Close()
+
+	m, err := metrics.NewHTTPMetrics(&metrics.HTTPConfig{
+		ValuePath:     "$.health.checks[1].score",
+		Retries:       2,
+		RetryInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := m.GetMetric(server.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 0.75 {
+		t.Errorf("expected 0.75, but got %f", v)
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 requests, but got %d", requests)
+	}
+}
+
+func TestHTTPMetrics_GetMetric_Request(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.Header.Get("X-Api-Key") != "secret" || string(body) != `{"service":"web"}` {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"error_rate": 1.5}`))
+	}))
+	defer server.Close()
+
+	m, err := metrics.NewHTTPMetrics(&metrics.HTTPConfig{ValuePath: ".error_rate"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := fmt.Sprintf("url: %s/query\nheaders:\n  X-Api-Key: secret\nbody: '{\"service\":\"web\"}'\n", server.URL)
+	v, err := m.GetMetric(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 1.5 {
+		t.Errorf("expected 1.5, but got %f", v)
+	}
+}
+
+func TestHTTPMetrics_GetMetric_NoData(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/null":
+			w.Write([]byte(`{"value":null}`))
+		default:
+			http.Error(w, "forbidden", http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	m, err := metrics.NewHTTPMetrics(&metrics.HTTPConfig{
+		ValuePath:         "$.value",
+		NoDataStatusCodes: []int{http.StatusNotFound},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/missing", "/null"} {
+		if _, err := m.GetMetric(server.URL + path); err == nil {
+			t.Errorf("%s: expected an error", path)
+		} else if _, ok := err.(*metrics.NoDataError); !ok {
+			t.Errorf("%s: expected NoDataError, but got %v", path, err)
+		}
+	}
+	if _, err := m.GetMetric(server.URL + "/forbidden"); err == nil {
+		t.Error("expected an error because of the status code")
+	} else if _, ok := err.(*metrics.NoDataError); ok {
+		t.Errorf("expected an error other than NoDataError, but got %v", err)
+	}
+}
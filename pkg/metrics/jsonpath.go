@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// lookupJSONPath returns the value at the path in a decoded JSON document.
+// The path is a dot-separated list of keys with optional array indexes, e.g.
+// `aggregations.error_rate.value` or `$.series[0].points[-1]`. Negative indexes
+// count from the end. Keys containing dots can be written as `['a.b']`.
+// It returns nil if the path does not exist.
+func lookupJSONPath(doc interface{}, path string) (interface{}, error) {
+	tokens, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	v := doc
+	for _, token := range tokens {
+		switch t := token.(type) {
+		case string:
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, nil
+			}
+			v = m[t]
+		case int:
+			a, ok := v.([]interface{})
+			if !ok {
+				return nil, nil
+			}
+			if t < 0 {
+				t += len(a)
+			}
+			if t < 0 || t >= len(a) {
+				return nil, nil
+			}
+			v = a[t]
+		}
+	}
+
+	return v, nil
+}
+
+func parseJSONPath(path string) ([]interface{}, error) {
+	p := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	var tokens []interface{}
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+		case '[':
+			end := strings.Index(p, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed bracket in path `%s`", path)
+			}
+			s := p[1:end]
+			p = p[end+1:]
+			if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+				tokens = append(tokens, s[1:len(s)-1])
+				continue
+			}
+			i, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index `%s` in path `%s`", s, path)
+			}
+			tokens = append(tokens, i)
+		default:
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			tokens = append(tokens, p[:end])
+			p = p[end:]
+		}
+	}
+
+	return tokens, nil
+}
+
+// jsonNumber converts a decoded JSON value to a number. Numeric strings are also accepted.
+func jsonNumber(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(n), 64)
+	case bool:
+		if n {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("value `%v` is not a number", v)
+}
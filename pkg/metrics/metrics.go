@@ -1,11 +1,21 @@
 package metrics
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type Metrics interface {
 	GetMetric(query string) (float64, error)
 }
 
+// SeriesMetrics is implemented by metrics that can return every datapoint in
+// the collection period instead of a single reduced value.
+type SeriesMetrics interface {
+	Metrics
+	GetMetricSeries(query string) ([]float64, error)
+}
+
 type NoDataError struct {
 	query string
 }
@@ -13,3 +23,18 @@ type NoDataError struct {
 func (e *NoDataError) Error() string {
 	return fmt.Sprintf("There was no data in the `%s`", e.query)
 }
+
+// TimeoutError is returned when a query did not complete in time. It is
+// temporary, so the query can be retried later.
+type TimeoutError struct {
+	query   string
+	timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("query `%s` did not complete within %s", e.query, e.timeout)
+}
+
+func (e *TimeoutError) Temporary() bool {
+	return true
+}
@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	otlpHTTPPath = "/v1/metrics"
+
+	otlpContentTypeProtobuf = "application/x-protobuf"
+	otlpContentTypeJSON     = "application/json"
+)
+
+func otlpAnyValueString(v *commonpb.AnyValue) string {
+	switch value := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return value.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(value.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(value.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(value.DoubleValue, 'f', -1, 64)
+	}
+	return ""
+}
+
+func otlpLabels(base map[string]string, attributes []*commonpb.KeyValue, labels []*commonpb.StringKeyValue) map[string]string {
+	l := make(map[string]string, len(base)+len(attributes)+len(labels))
+	for k, v := range base {
+		l[k] = v
+	}
+	for _, kv := range attributes {
+		l[kv.GetKey()] = otlpAnyValueString(kv.GetValue())
+	}
+	for _, kv := range labels {
+		l[kv.GetKey()] = kv.GetValue()
+	}
+	return l
+}
+
+func otlpTime(unixNano uint64) time.Time {
+	if unixNano == 0 {
+		return time.Now()
+	}
+	return time.Unix(0, int64(unixNano))
+}
+
+func otlpMean(sum float64, count uint64) (float64, bool) {
+	if count == 0 {
+		return 0, false
+	}
+	return sum / float64(count), true
+}
+
+// addOTLPMetrics adds the data points to the store. Resource attributes are
+// merged into the labels of the data points. Histograms and summaries are
+// recorded as the mean of each data point.
+func addOTLPMetrics(store *pushStore, req *colmetricspb.ExportMetricsServiceRequest) {
+	for _, rm := range req.GetResourceMetrics() {
+		resource := otlpLabels(nil, rm.GetResource().GetAttributes(), nil)
+		for _, ilm := range rm.GetInstrumentationLibraryMetrics() {
+			for _, m := range ilm.GetMetrics() {
+				name := m.GetName()
+				switch data := m.GetData().(type) {
+				case *metricspb.Metric_Gauge:
+					for _, dp := range data.Gauge.GetDataPoints() {
+						store.add(name, otlpLabels(resource, dp.GetAttributes(), dp.GetLabels()), otlpTime(dp.GetTimeUnixNano()), otlpNumber(dp))
+					}
+				case *metricspb.Metric_Sum:
+					for _, dp := range data.Sum.GetDataPoints() {
+						store.add(name, otlpLabels(resource, dp.GetAttributes(), dp.GetLabels()), otlpTime(dp.GetTimeUnixNano()), otlpNumber(dp))
+					}
+				case *metricspb.Metric_IntGauge:
+					for _, dp := range data.IntGauge.GetDataPoints() {
+						store.add(name, otlpLabels(resource, nil, dp.GetLabels()), otlpTime(dp.GetTimeUnixNano()), float64(dp.GetValue()))
+					}
+				case *metricspb.Metric_IntSum:
+					for _, dp := range data.IntSum.GetDataPoints() {
+						store.add(name, otlpLabels(resource, nil, dp.GetLabels()), otlpTime(dp.GetTimeUnixNano()), float64(dp.GetValue()))
+					}
+				case *metricspb.Metric_Histogram:
+					for _, dp := range data.Histogram.GetDataPoints() {
+						if v, ok := otlpMean(dp.GetSum(), dp.GetCount()); ok {
+							store.add(name, otlpLabels(resource, dp.GetAttributes(), dp.GetLabels()), otlpTime(dp.GetTimeUnixNano()), v)
+						}
+					}
+				case *metricspb.Metric_IntHistogram:
+					for _, dp := range data.IntHistogram.GetDataPoints() {
+						if v, ok := otlpMean(float64(dp.GetSum()), dp.GetCount()); ok {
+							store.add(name, otlpLabels(resource, nil, dp.GetLabels()), otlpTime(dp.GetTimeUnixNano()), v)
+						}
+					}
+				case *metricspb.Metric_Summary:
+					for _, dp := range data.Summary.GetDataPoints() {
+						if v, ok := otlpMean(dp.GetSum(), dp.GetCount()); ok {
+							store.add(name, otlpLabels(resource, dp.GetAttributes(), dp.GetLabels()), otlpTime(dp.GetTimeUnixNano()), v)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func otlpNumber(dp *metricspb.NumberDataPoint) float64 {
+	switch v := dp.GetValue().(type) {
+	case *metricspb.NumberDataPoint_AsDouble:
+		return v.AsDouble
+	case *metricspb.NumberDataPoint_AsInt:
+		return float64(v.AsInt)
+	}
+	return 0
+}
+
+type otlpHTTPHandler struct {
+	store *pushStore
+}
+
+func (h *otlpHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != otlpHTTPPath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := &colmetricspb.ExportMetricsServiceRequest{}
+	contentType := strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0])
+	switch contentType {
+	case otlpContentTypeProtobuf:
+		err = proto.Unmarshal(body, req)
+	case otlpContentTypeJSON:
+		err = protojson.Unmarshal(body, req)
+	default:
+		http.Error(w, fmt.Sprintf("unsupported content type `%s`", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	addOTLPMetrics(h.store, req)
+
+	res := &colmetricspb.ExportMetricsServiceResponse{}
+	var b []byte
+	if contentType == otlpContentTypeJSON {
+		b, err = protojson.Marshal(res)
+	} else {
+		b, err = proto.Marshal(res)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(b)
+}
+
+func listenOTLPHTTP(address string, store *pushStore) (*http.Server, error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen OTLP over HTTP: %w", err)
+	}
+	server := &http.Server{Handler: &otlpHTTPHandler{store: store}}
+	go server.Serve(l)
+
+	return server, nil
+}
+
+type otlpGRPCService struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	store *pushStore
+}
+
+func (s *otlpGRPCService) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	addOTLPMetrics(s.store, req)
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+type grpcServerCloser struct {
+	server *grpc.Server
+}
+
+func (c *grpcServerCloser) Close() error {
+	c.server.GracefulStop()
+	return nil
+}
+
+func listenOTLPGRPC(address string, store *pushStore) (*grpcServerCloser, error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen OTLP over gRPC: %w", err)
+	}
+	server := grpc.NewServer()
+	colmetricspb.RegisterMetricsServiceServer(server, &otlpGRPCService{store: store})
+	go server.Serve(l)
+
+	return &grpcServerCloser{server: server}, nil
+}
@@ -0,0 +1,337 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ProbeMetricsType = "probe"
+
+	ProbeTypeHTTP = "http"
+	ProbeTypeTCP  = "tcp"
+	ProbeTypeDNS  = "dns"
+
+	ProbeQuerySuccessRate  = "success_rate"
+	ProbeQueryErrorRate    = "error_rate"
+	ProbeQueryRequestCount = "request_count"
+	// ProbeQueryLatency is followed by a reduction, e.g. `latency_p99` or `latency_average`.
+	ProbeQueryLatency = "latency_"
+
+	probeDefaultInterval = time.Second
+	probeDefaultTimeout  = 5 * time.Second
+)
+
+type ProbeTarget struct {
+	// Name is used in the query to select the target, e.g. `source` or `destination`.
+	Name string
+	// Type is either ProbeTypeHTTP, ProbeTypeTCP or ProbeTypeDNS.
+	Type string
+	// Address is a URL for ProbeTypeHTTP, `host:port` for ProbeTypeTCP and a host name for ProbeTypeDNS.
+	Address string
+
+	// Method of ProbeTypeHTTP. Defaults to GET.
+	Method  string
+	Headers map[string]string
+	// StatusCodes are the status codes treated as success for ProbeTypeHTTP. Defaults to any 2xx.
+	StatusCodes []int
+	// DNSServer is `host:port` of the name server for ProbeTypeDNS. Defaults to the system resolver.
+	DNSServer string
+}
+
+type ProbeConfig struct {
+	Client *http.Client
+
+	Targets []*ProbeTarget
+	// Interval is how often each target is probed. Defaults to 1 second.
+	Interval time.Duration
+	// Timeout of each probe. Defaults to 5 seconds.
+	Timeout time.Duration
+	// Period is how long the results are kept.
+	Period time.Duration
+
+	TLS *TLSConfig
+}
+
+type probeResult struct {
+	time    time.Time
+	success bool
+	latency float64
+}
+
+type prober struct {
+	target   *ProbeTarget
+	client   *http.Client
+	resolver *net.Resolver
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	results []probeResult
+}
+
+// ProbeMetrics sends synthetic checks to the targets in the background and aggregates the results in the collection period.
+// The targets are probed once by NewProbeMetrics before it returns, so a process that evaluates the metrics right after
+// creating them, such as the update and rollback commands, sees only that first round. The run command keeps probing.
+type ProbeMetrics struct {
+	probers map[string]*prober
+	period  time.Duration
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func (p *prober) probe(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	switch p.target.Type {
+	case ProbeTypeHTTP:
+		req, err := http.NewRequestWithContext(ctx, p.target.Method, p.target.Address, nil)
+		if err != nil {
+			return err
+		}
+		for name, value := range p.target.Headers {
+			req.Header.Set(name, value)
+		}
+		res, err := p.client.Do(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		ok := res.StatusCode >= 200 && res.StatusCode < 300
+		if len(p.target.StatusCodes) > 0 {
+			ok = containsStatusCode(p.target.StatusCodes, res.StatusCode)
+		}
+		if !ok {
+			return fmt.Errorf("unexpected status code %d", res.StatusCode)
+		}
+	case ProbeTypeTCP:
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", p.target.Address)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+	case ProbeTypeDNS:
+		addrs, err := p.resolver.LookupHost(ctx, p.target.Address)
+		if err != nil {
+			return err
+		}
+		if len(addrs) == 0 {
+			return fmt.Errorf("no address for `%s`", p.target.Address)
+		}
+	}
+	return nil
+}
+
+// record probes the target once and keeps the result in the period.
+func (p *prober) record(ctx context.Context, period time.Duration) {
+	start := time.Now()
+	err := p.probe(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+	result := probeResult{time: start, success: err == nil, latency: time.Since(start).Seconds()}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.results = append(p.results, result)
+	since := time.Now().Add(-period)
+	i := 0
+	for i < len(p.results) && p.results[i].time.Before(since) {
+		i++
+	}
+	p.results = p.results[i:]
+}
+
+func (p *prober) run(ctx context.Context, interval, period time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		p.record(ctx, period)
+	}
+}
+
+// GetMetric returns the statistic of the target named by the query in the form of `<target>:<statistic>`:
+// `success_rate` and `error_rate` in percent, `request_count` or `latency_` followed by a reduction such as `latency_p99`.
+// The latency is in seconds and only successful probes are counted.
+func (m *ProbeMetrics) GetMetric(query string) (float64, error) {
+	q := strings.TrimSpace(query)
+	parts := strings.SplitN(q, ":", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("probe query must be `<target>:<statistic>`, but got `%s`", q)
+	}
+	name, stat := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	p, ok := m.probers[name]
+	if !ok {
+		return 0, fmt.Errorf("probe target `%s` is not found", name)
+	}
+	var reduction *Reduction
+	switch {
+	case stat == ProbeQuerySuccessRate, stat == ProbeQueryErrorRate, stat == ProbeQueryRequestCount:
+	case strings.HasPrefix(stat, ProbeQueryLatency):
+		r, err := ParseReduction(strings.TrimPrefix(stat, ProbeQueryLatency))
+		if err != nil {
+			return 0, fmt.Errorf("invalid probe query `%s`: %w", q, err)
+		}
+		reduction = r
+	default:
+		return 0, fmt.Errorf("probe statistic can be either \"%s\", \"%s\", \"%s\" or \"%s\" followed by a reduction, but got `%s`", ProbeQuerySuccessRate, ProbeQueryErrorRate, ProbeQueryRequestCount, ProbeQueryLatency, stat)
+	}
+
+	p.mu.Lock()
+	since := time.Now().Add(-m.period)
+	var requests, successes int
+	var latencies []float64
+	for _, r := range p.results {
+		if r.time.Before(since) {
+			continue
+		}
+		requests++
+		if r.success {
+			successes++
+			latencies = append(latencies, r.latency)
+		}
+	}
+	p.mu.Unlock()
+
+	switch stat {
+	case ProbeQueryRequestCount:
+		return float64(requests), nil
+	case ProbeQuerySuccessRate:
+		if requests == 0 {
+			return 0, &NoDataError{query: q}
+		}
+		return float64(successes) / float64(requests) * 100, nil
+	case ProbeQueryErrorRate:
+		if requests == 0 {
+			return 0, &NoDataError{query: q}
+		}
+		return float64(requests-successes) / float64(requests) * 100, nil
+	}
+	if len(latencies) == 0 {
+		return 0, &NoDataError{query: q}
+	}
+	return reduction.Reduce(latencies)
+}
+
+// Close stops probing.
+func (m *ProbeMetrics) Close() error {
+	m.cancel()
+	m.wg.Wait()
+	return nil
+}
+
+func NewProbeMetrics(config *ProbeConfig) (*ProbeMetrics, error) {
+	if len(config.Targets) == 0 {
+		return nil, errors.New("ProbeConfig.Targets is missing")
+	}
+	if config.Period <= 0 {
+		return nil, errors.New("ProbeConfig.Period must be greater than 0")
+	}
+	interval := config.Interval
+	if interval <= 0 {
+		interval = probeDefaultInterval
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = probeDefaultTimeout
+	}
+	client := config.Client
+	if client == nil {
+		c, err := newHTTPClient(config.TLS, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("ProbeConfig.TLS: %w", err)
+		}
+		// a redirect is a response of the target
+		c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		client = c
+	}
+
+	probers := make(map[string]*prober)
+	for i, target := range config.Targets {
+		if target.Name == "" {
+			return nil, fmt.Errorf("ProbeConfig.Targets[%d].Name is missing", i)
+		}
+		if _, ok := probers[target.Name]; ok {
+			return nil, fmt.Errorf("ProbeConfig.Targets[%d].Name `%s` is duplicated", i, target.Name)
+		}
+		if target.Address == "" {
+			return nil, fmt.Errorf("ProbeConfig.Targets[%d].Address is missing", i)
+		}
+		t := *target
+		switch t.Type {
+		case ProbeTypeHTTP:
+			if !strings.HasPrefix(t.Address, "http://") && !strings.HasPrefix(t.Address, "https://") {
+				return nil, fmt.Errorf("ProbeConfig.Targets[%d].Address must be a URL when the type is \"%s\"", i, ProbeTypeHTTP)
+			}
+			if t.Method == "" {
+				t.Method = http.MethodGet
+			}
+			t.Method = strings.ToUpper(t.Method)
+		case ProbeTypeTCP:
+			if _, _, err := net.SplitHostPort(t.Address); err != nil {
+				return nil, fmt.Errorf("ProbeConfig.Targets[%d].Address: %w", i, err)
+			}
+		case ProbeTypeDNS:
+		default:
+			return nil, fmt.Errorf("ProbeConfig.Targets[%d].Type can be either \"%s\", \"%s\", \"%s\"", i, ProbeTypeHTTP, ProbeTypeTCP, ProbeTypeDNS)
+		}
+
+		resolver := net.DefaultResolver
+		if t.DNSServer != "" {
+			server := t.DNSServer
+			resolver = &net.Resolver{
+				PreferGo: true,
+				Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, network, server)
+				},
+			}
+		}
+		probers[t.Name] = &prober{
+			target:   &t,
+			client:   client,
+			resolver: resolver,
+			timeout:  timeout,
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &ProbeMetrics{
+		probers: probers,
+		period:  config.Period,
+		cancel:  cancel,
+	}
+	// the first round is probed before returning so that the metrics have a result from the start
+	var wg sync.WaitGroup
+	for _, p := range probers {
+		wg.Add(1)
+		go func(p *prober) {
+			defer wg.Done()
+			p.record(ctx, config.Period)
+		}(p)
+	}
+	wg.Wait()
+	for _, p := range probers {
+		m.wg.Add(1)
+		go func(p *prober) {
+			defer m.wg.Done()
+			p.run(ctx, interval, config.Period)
+		}(p)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,88 @@
+package metrics_test
+
+import (
+	"github.com/k-kinzal/progressived/pkg/metrics"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProbeMetrics_GetMetric(t *testing.T) {
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer source.Close()
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer destination.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	m, err := metrics.NewProbeMetrics(&metrics.ProbeConfig{
+		Targets: []*metrics.ProbeTarget{
+			{Name: "source", Type: metrics.ProbeTypeHTTP, Address: source.URL},
+			{Name: "destination", Type: metrics.ProbeTypeHTTP, Address: destination.URL},
+			{Name: "tcp", Type: metrics.ProbeTypeTCP, Address: l.Addr().String()},
+		},
+		Interval: 10 * time.Millisecond,
+		Period:   time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// the first round is probed before NewProbeMetrics returns
+	if v, err := m.GetMetric("source:request_count"); err != nil || v < 1 {
+		t.Fatalf("expected the first probe of the source, but got %f, %v", v, err)
+	}
+
+	// the targets are probed again in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		v, err := m.GetMetric("tcp:request_count")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the tcp target to be probed again, but got %f probes", v)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cases := []struct {
+		query    string
+		expected float64
+	}{
+		{"source:success_rate", 100},
+		{"destination:error_rate", 100},
+		{"tcp:success_rate", 100},
+	}
+	for _, c := range cases {
+		v, err := m.GetMetric(c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != c.expected {
+			t.Errorf("%s: expected %f, but got %f", c.query, c.expected, v)
+		}
+	}
+	if v, err := m.GetMetric("source:latency_p99"); err != nil || v <= 0 {
+		t.Errorf("expected the latency of the source, but got %f, %v", v, err)
+	}
+	if _, err := m.GetMetric("destination:latency_p99"); err == nil {
+		t.Error("expected NoDataError because no probe to the destination succeeded")
+	}
+	if _, err := m.GetMetric("unknown:success_rate"); err == nil {
+		t.Error("expected an error for the unknown target")
+	}
+}
@@ -0,0 +1,286 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PushMetricsType = "push"
+)
+
+var (
+	pushQueryRegexp    = regexp.MustCompile(`^(?:([a-zA-Z0-9.]+)\()?\s*([^{}()\s]+)\s*(?:\{([^}]*)\})?\s*\)?$`)
+	pushSelectorRegexp = regexp.MustCompile(`^\s*([^=\s]+)\s*=\s*"([^"]*)"\s*$`)
+)
+
+type PushConfig struct {
+	// OTLPHTTPAddress is the address to receive OTLP metrics over HTTP, e.g. `:4318`.
+	OTLPHTTPAddress string
+	// OTLPGRPCAddress is the address to receive OTLP metrics over gRPC, e.g. `:4317`.
+	OTLPGRPCAddress string
+	// StatsDAddress is the UDP address to receive StatsD metrics, e.g. `:8125`.
+	StatsDAddress string
+
+	// Window is how long the samples are kept.
+	Window    time.Duration
+	Reduction *Reduction
+}
+
+type pushSample struct {
+	time  time.Time
+	value float64
+}
+
+type pushSeries struct {
+	name    string
+	labels  map[string]string
+	samples []pushSample
+}
+
+// pushStore keeps the samples in the window per metric name and label set.
+type pushStore struct {
+	mu     sync.Mutex
+	window time.Duration
+	series map[string]*pushSeries
+	// last keeps the latest value per series after the samples expire, which is the base of gauge deltas
+	last map[string]float64
+}
+
+func pushSeriesKey(name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\x00%s=%s", k, labels[k])
+	}
+	return b.String()
+}
+
+func (s *pushStore) add(name string, labels map[string]string, t time.Time, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.append(name, labels, t, value)
+}
+
+// addDelta adds the delta to the latest value of the series, which is 0 if nothing is received yet.
+func (s *pushStore) addDelta(name string, labels map[string]string, t time.Time, delta float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.append(name, labels, t, s.last[pushSeriesKey(name, labels)]+delta)
+}
+
+func (s *pushStore) append(name string, labels map[string]string, t time.Time, value float64) {
+	key := pushSeriesKey(name, labels)
+	s.last[key] = value
+	series, ok := s.series[key]
+	if !ok {
+		series = &pushSeries{name: name, labels: labels}
+		s.series[key] = series
+	}
+	series.samples = append(series.samples, pushSample{time: t, value: value})
+
+	// samples mostly arrive in order, so dropping the expired ones from the head bounds the memory
+	since := time.Now().Add(-s.window)
+	i := 0
+	for i < len(series.samples) && series.samples[i].time.Before(since) {
+		i++
+	}
+	series.samples = series.samples[i:]
+}
+
+// expire drops the samples older than the window.
+func (s *pushStore) expire(now time.Time) {
+	since := now.Add(-s.window)
+	for key, series := range s.series {
+		samples := series.samples[:0]
+		for _, sample := range series.samples {
+			if !sample.time.Before(since) {
+				samples = append(samples, sample)
+			}
+		}
+		series.samples = samples
+		if len(samples) == 0 {
+			delete(s.series, key)
+		}
+	}
+}
+
+// values returns the samples of all series that have the name and the labels, ordered from the oldest to the latest.
+func (s *pushStore) values(name string, labels map[string]string) []float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(time.Now())
+
+	var samples []pushSample
+	for _, series := range s.series {
+		if series.name != name {
+			continue
+		}
+		matched := true
+		for k, v := range labels {
+			if series.labels[k] != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			samples = append(samples, series.samples...)
+		}
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].time.Before(samples[j].time)
+	})
+
+	values := make([]float64, len(samples))
+	for i, sample := range samples {
+		values[i] = sample.value
+	}
+	return values
+}
+
+// PushMetrics receives metrics pushed over OTLP and StatsD and keeps them in memory.
+type PushMetrics struct {
+	store     *pushStore
+	reduction *Reduction
+	closers   []io.Closer
+}
+
+type pushQuery struct {
+	reduction *Reduction
+	name      string
+	labels    map[string]string
+}
+
+// parsePushQuery parses a query such as `http.server.errors{version="green"}`.
+// It can be wrapped by a reduction, e.g. `p99(http.server.duration{version="green"})`.
+func parsePushQuery(query string) (*pushQuery, error) {
+	match := pushQueryRegexp.FindStringSubmatch(strings.TrimSpace(query))
+	if match == nil {
+		return nil, fmt.Errorf("invalid push metrics query `%s`", query)
+	}
+
+	q := &pushQuery{name: match[2], labels: make(map[string]string)}
+	if match[1] != "" {
+		r, err := ParseReduction(match[1])
+		if err != nil {
+			return nil, err
+		}
+		q.reduction = r
+	}
+	if strings.TrimSpace(match[3]) != "" {
+		for _, selector := range strings.Split(match[3], ",") {
+			m := pushSelectorRegexp.FindStringSubmatch(selector)
+			if m == nil {
+				return nil, fmt.Errorf("invalid label selector `%s` in `%s`", selector, query)
+			}
+			q.labels[m[1]] = m[2]
+		}
+	}
+	return q, nil
+}
+
+func (m *PushMetrics) values(q *pushQuery, query string) ([]float64, error) {
+	values := m.store.values(q.name, q.labels)
+	if len(values) < 1 {
+		return nil, &NoDataError{query: query}
+	}
+	return values, nil
+}
+
+func (m *PushMetrics) GetMetricSeries(query string) ([]float64, error) {
+	q, err := parsePushQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return m.values(q, query)
+}
+
+func (m *PushMetrics) GetMetric(query string) (float64, error) {
+	q, err := parsePushQuery(query)
+	if err != nil {
+		return 0, err
+	}
+	values, err := m.values(q, query)
+	if err != nil {
+		return 0, err
+	}
+	reduction := m.reduction
+	if q.reduction != nil {
+		reduction = q.reduction
+	}
+	return reduction.Reduce(values)
+}
+
+// Close stops all receivers.
+func (m *PushMetrics) Close() error {
+	var err error
+	for _, c := range m.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func NewPushMetrics(config *PushConfig) (*PushMetrics, error) {
+	if config.OTLPHTTPAddress == "" && config.OTLPGRPCAddress == "" && config.StatsDAddress == "" {
+		return nil, errors.New("at least one of PushConfig.OTLPHTTPAddress, PushConfig.OTLPGRPCAddress and PushConfig.StatsDAddress must be set")
+	}
+	if config.Window <= 0 {
+		return nil, errors.New("PushConfig.Window must be greater than 0")
+	}
+	reduction := config.Reduction
+	if reduction == nil {
+		reduction = &Reduction{name: ReductionLatest}
+	}
+
+	m := &PushMetrics{
+		store: &pushStore{
+			window: config.Window,
+			series: make(map[string]*pushSeries),
+			last:   make(map[string]float64),
+		},
+		reduction: reduction,
+	}
+	if config.OTLPHTTPAddress != "" {
+		c, err := listenOTLPHTTP(config.OTLPHTTPAddress, m.store)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.closers = append(m.closers, c)
+	}
+	if config.OTLPGRPCAddress != "" {
+		c, err := listenOTLPGRPC(config.OTLPGRPCAddress, m.store)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.closers = append(m.closers, c)
+	}
+	if config.StatsDAddress != "" {
+		c, err := listenStatsD(config.StatsDAddress, m.store)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.closers = append(m.closers, c)
+	}
+
+	return m, nil
+}
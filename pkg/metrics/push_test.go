@@ -0,0 +1,152 @@
+package metrics_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/k-kinzal/progressived/pkg/metrics"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func freeAddress(t *testing.T, network string) string {
+	var addr string
+	if network == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr = conn.LocalAddr().String()
+		conn.Close()
+	} else {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr = l.Addr().String()
+		l.Close()
+	}
+	return addr
+}
+
+// waitMetric polls the metric until the pushed samples are received.
+func waitMetric(m *metrics.PushMetrics, query string) (float64, error) {
+	var v float64
+	var err error
+	for i := 0; i < 50; i++ {
+		v, err = m.GetMetric(query)
+		if err == nil {
+			return v, nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return v, err
+}
+
+func TestPushMetrics_StatsD(t *testing.T) {
+	address := freeAddress(t, "udp")
+	m, err := metrics.NewPushMetrics(&metrics.PushConfig{StatsDAddress: address, Window: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "http.errors:1|c|@0.5|#version:green\nhttp.errors:5|c|#version:blue")
+
+	v, err := waitMetric(m, `sum(http.errors{version="green"})`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 2 {
+		t.Errorf("expected 2, but got %f", v)
+	}
+	if _, err := m.GetMetric(`http.errors{version="red"}`); err == nil {
+		t.Error("expected NoDataError")
+	}
+}
+
+func TestPushMetrics_StatsDGaugeDelta(t *testing.T) {
+	address := freeAddress(t, "udp")
+	m, err := metrics.NewPushMetrics(&metrics.PushConfig{StatsDAddress: address, Window: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "queue.size:10|g\nqueue.size:+5|g\nqueue.size:-3|g")
+
+	var v float64
+	for i := 0; i < 50; i++ {
+		v, err = m.GetMetric("queue.size")
+		if err == nil && v == 12 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 12 {
+		t.Errorf("expected 12, but got %f", v)
+	}
+}
+
+func TestPushMetrics_OTLPHTTP(t *testing.T) {
+	address := freeAddress(t, "tcp")
+	m, err := metrics.NewPushMetrics(&metrics.PushConfig{OTLPHTTPAddress: address, Window: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	now := time.Now().UnixNano()
+	body := fmt.Sprintf(`{
+  "resourceMetrics": [{
+    "resource": {"attributes": [{"key": "service.version", "value": {"stringValue": "green"}}]},
+    "instrumentationLibraryMetrics": [{
+      "metrics": [{
+        "name": "http.server.duration",
+        "gauge": {"dataPoints": [
+          {"timeUnixNano": "%d", "asDouble": 0.1},
+          {"timeUnixNano": "%d", "asDouble": 0.3}
+        ]}
+      }]
+    }]
+  }]
+}`, now-int64(time.Second), now)
+
+	var res *http.Response
+	for i := 0; i < 50; i++ {
+		res, err = http.Post(fmt.Sprintf("http://%s/v1/metrics", address), "application/json", bytes.NewBufferString(body))
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, but got %d", res.StatusCode)
+	}
+
+	v, err := m.GetMetric(`average(http.server.duration{service.version="green"})`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v < 0.199 || v > 0.201 {
+		t.Errorf("expected 0.2, but got %f", v)
+	}
+}
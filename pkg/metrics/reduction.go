@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ReductionLatest  = "latest"
+	ReductionAverage = "average"
+	ReductionMin     = "min"
+	ReductionMax     = "max"
+	ReductionSum     = "sum"
+	// ReductionPercentile is written as `p` followed by the percentile, e.g. `p99` or `p99.9`.
+	ReductionPercentile = "p"
+)
+
+// Reduction reduces a series of datapoints to a single value.
+type Reduction struct {
+	name       string
+	percentile float64
+}
+
+// Reduce returns the reduced value of values, which are ordered from the oldest to the latest.
+func (r *Reduction) Reduce(values []float64) (float64, error) {
+	if len(values) == 0 {
+		return 0, fmt.Errorf("reduction `%s` requires at least one value", r)
+	}
+
+	switch r.name {
+	case ReductionLatest:
+		return values[len(values)-1], nil
+	case ReductionAverage:
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values)), nil
+	case ReductionMin:
+		min := math.Inf(1)
+		for _, v := range values {
+			min = math.Min(min, v)
+		}
+		return min, nil
+	case ReductionMax:
+		max := math.Inf(-1)
+		for _, v := range values {
+			max = math.Max(max, v)
+		}
+		return max, nil
+	case ReductionSum:
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum, nil
+	case ReductionPercentile:
+		return percentile(values, r.percentile), nil
+	}
+
+	return 0, fmt.Errorf("unknown reduction `%s`", r)
+}
+
+func (r *Reduction) String() string {
+	if r.name == ReductionPercentile {
+		return ReductionPercentile + strconv.FormatFloat(r.percentile, 'f', -1, 64)
+	}
+	return r.name
+}
+
+// percentile returns the p-th percentile of values using linear interpolation between closest ranks.
+func percentile(values []float64, p float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// ParseReduction parses the name of a reduction. An empty name is treated as `latest`.
+func ParseReduction(s string) (*Reduction, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	switch name {
+	case "":
+		return &Reduction{name: ReductionLatest}, nil
+	case ReductionLatest, ReductionAverage, ReductionMin, ReductionMax, ReductionSum:
+		return &Reduction{name: name}, nil
+	case "avg", "mean":
+		return &Reduction{name: ReductionAverage}, nil
+	}
+
+	if strings.HasPrefix(name, ReductionPercentile) {
+		p, err := strconv.ParseFloat(strings.TrimPrefix(name, ReductionPercentile), 64)
+		if err == nil && p >= 0 && p <= 100 {
+			return &Reduction{name: ReductionPercentile, percentile: p}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("reduction can be either \"%s\", \"%s\", \"%s\", \"%s\", \"%s\" or a percentile such as \"p99\", but got `%s`", ReductionLatest, ReductionAverage, ReductionMin, ReductionMax, ReductionSum, s)
+}
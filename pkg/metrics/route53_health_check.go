@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"strings"
+	"sync"
+)
+
+const (
+	Route53HealthCheckMetricsType = "route53-health-check"
+
+	// Route53HealthCheckCondition is the condition that passes while at least half of the checkers report healthy.
+	Route53HealthCheckCondition = "x >= 0.5"
+)
+
+type Route53HealthCheckConfig struct {
+	Sess *session.Session
+
+	Client Route53HealthCheckClient
+
+	// HealthCheckId is the health check to read. When it is missing, the health
+	// check associated with the record set is used.
+	HealthCheckId string
+
+	// HostedZoneId, RecordName and SetIdentifier select the record set. RecordName matches regardless of
+	// the case and the trailing dot, and SetIdentifier matches exactly. Type selects the record type when
+	// the record sets of several types share the identifier.
+	HostedZoneId  string
+	RecordName    string
+	SetIdentifier string
+	Type          string
+}
+
+type Route53HealthCheckClient interface {
+	ListResourceRecordSets(input *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error)
+	GetHealthCheckStatus(input *route53.GetHealthCheckStatusInput) (*route53.GetHealthCheckStatusOutput, error)
+}
+
+// Route53HealthCheckMetrics returns the fraction of the Route53 health checkers that report healthy.
+type Route53HealthCheckMetrics struct {
+	client        Route53HealthCheckClient
+	healthCheckId string
+	hostedZoneId  string
+	recordName    string
+	setIdentifier string
+	recordType    string
+
+	mu sync.Mutex
+}
+
+// normalizeRecordName returns the name in the form to compare, without the trailing dot and in lower case.
+func normalizeRecordName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// discoverHealthCheckId returns the health check associated with the record set that matches.
+func (m *Route53HealthCheckMetrics) discoverHealthCheckId() (string, error) {
+	recordName := normalizeRecordName(m.recordName)
+	input := &route53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(m.hostedZoneId),
+		// the record sets are listed from the name
+		StartRecordName: aws.String(recordName + "."),
+	}
+	if m.recordType != "" {
+		input.StartRecordType = aws.String(m.recordType)
+	}
+	for {
+		res, err := m.client.ListResourceRecordSets(input)
+		if err != nil {
+			return "", fmt.Errorf("failed to list route53 record sets: %w", err)
+		}
+		for _, r := range res.ResourceRecordSets {
+			if normalizeRecordName(aws.StringValue(r.Name)) != recordName {
+				continue
+			}
+			if aws.StringValue(r.SetIdentifier) != m.setIdentifier {
+				continue
+			}
+			if m.recordType != "" && aws.StringValue(r.Type) != m.recordType {
+				continue
+			}
+			if r.HealthCheckId == nil {
+				return "", fmt.Errorf("record set `%s` with identifier `%s` has no health check", aws.StringValue(r.Name), aws.StringValue(r.SetIdentifier))
+			}
+			return aws.StringValue(r.HealthCheckId), nil
+		}
+		if !aws.BoolValue(res.IsTruncated) {
+			break
+		}
+		input.StartRecordIdentifier = res.NextRecordIdentifier
+		input.StartRecordName = res.NextRecordName
+		input.StartRecordType = res.NextRecordType
+	}
+	return "", fmt.Errorf("record set `%s` with identifier `%s` is not found", m.recordName, m.setIdentifier)
+}
+
+// GetMetric returns the fraction of the checkers that report healthy, from 0 to 1.
+// The query is an optional health check ID that overrides the configured one.
+func (m *Route53HealthCheckMetrics) GetMetric(query string) (float64, error) {
+	id := strings.TrimSpace(query)
+	if id == "" {
+		m.mu.Lock()
+		if m.healthCheckId == "" {
+			discovered, err := m.discoverHealthCheckId()
+			if err != nil {
+				m.mu.Unlock()
+				return 0, err
+			}
+			m.healthCheckId = discovered
+		}
+		id = m.healthCheckId
+		m.mu.Unlock()
+	}
+
+	res, err := m.client.GetHealthCheckStatus(&route53.GetHealthCheckStatusInput{
+		HealthCheckId: aws.String(id),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get route53 health check status: %w", err)
+	}
+	if len(res.HealthCheckObservations) < 1 {
+		return 0, &NoDataError{query: id}
+	}
+
+	healthy := 0
+	for _, o := range res.HealthCheckObservations {
+		if o.StatusReport != nil && strings.HasPrefix(aws.StringValue(o.StatusReport.Status), "Success") {
+			healthy++
+		}
+	}
+
+	return float64(healthy) / float64(len(res.HealthCheckObservations)), nil
+}
+
+func NewRoute53HealthCheckMetrics(config *Route53HealthCheckConfig) (*Route53HealthCheckMetrics, error) {
+	if config.HealthCheckId == "" {
+		if config.HostedZoneId == "" {
+			return nil, errors.New("Route53HealthCheckConfig.HostedZoneId must be set when Route53HealthCheckConfig.HealthCheckId is missing")
+		}
+		if config.RecordName == "" {
+			return nil, errors.New("Route53HealthCheckConfig.RecordName must be set when Route53HealthCheckConfig.HealthCheckId is missing")
+		}
+		if config.SetIdentifier == "" {
+			return nil, errors.New("Route53HealthCheckConfig.SetIdentifier must be set when Route53HealthCheckConfig.HealthCheckId is missing")
+		}
+	}
+	client := config.Client
+	if client == nil {
+		if config.Sess == nil {
+			return nil, errors.New("Route53HealthCheckConfig.Sess must be set when Route53HealthCheckConfig.Client is missing")
+		}
+		client = route53.New(config.Sess)
+	}
+
+	return &Route53HealthCheckMetrics{
+		client:        client,
+		healthCheckId: config.HealthCheckId,
+		hostedZoneId:  config.HostedZoneId,
+		recordName:    config.RecordName,
+		setIdentifier: config.SetIdentifier,
+		recordType:    config.Type,
+	}, nil
+}
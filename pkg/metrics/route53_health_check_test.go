@@ -0,0 +1,124 @@
+package metrics_test
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/k-kinzal/progressived/pkg/metrics"
+	"testing"
+)
+
+type fakeRoute53HealthCheckClient struct {
+	records  []*route53.ResourceRecordSet
+	statuses map[string][]string
+}
+
+func (c *fakeRoute53HealthCheckClient) ListResourceRecordSets(input *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	return &route53.ListResourceRecordSetsOutput{ResourceRecordSets: c.records, IsTruncated: aws.Bool(false)}, nil
+}
+
+func (c *fakeRoute53HealthCheckClient) GetHealthCheckStatus(input *route53.GetHealthCheckStatusInput) (*route53.GetHealthCheckStatusOutput, error) {
+	var observations []*route53.HealthCheckObservation
+	for _, status := range c.statuses[aws.StringValue(input.HealthCheckId)] {
+		observations = append(observations, &route53.HealthCheckObservation{
+			StatusReport: &route53.StatusReport{Status: aws.String(status)},
+		})
+	}
+	return &route53.GetHealthCheckStatusOutput{HealthCheckObservations: observations}, nil
+}
+
+func TestRoute53HealthCheckMetrics_GetMetric(t *testing.T) {
+	client := &fakeRoute53HealthCheckClient{
+		records: []*route53.ResourceRecordSet{
+			{Name: aws.String("example.com."), SetIdentifier: aws.String("app-blue"), HealthCheckId: aws.String("hc-blue")},
+			{Name: aws.String("example.com."), SetIdentifier: aws.String("app-green"), HealthCheckId: aws.String("hc-green")},
+			{Name: aws.String("other.example.com."), SetIdentifier: aws.String("green")},
+		},
+		statuses: map[string][]string{
+			"hc-blue":  {"Success: HTTP Status Code 200, OK", "Success: HTTP Status Code 200, OK"},
+			"hc-green": {"Success: HTTP Status Code 200, OK", "Failure: HTTP Status Code 503", "Failure: Connection timed out", "Success: HTTP Status Code 200, OK"},
+			"hc-empty": nil,
+		},
+	}
+	m, err := metrics.NewRoute53HealthCheckMetrics(&metrics.Route53HealthCheckConfig{
+		Client:        client,
+		HostedZoneId:  "Z1",
+		RecordName:    "example.com",
+		SetIdentifier: "app-green",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := m.GetMetric("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 0.5 {
+		t.Errorf("expected 0.5, but got %f", v)
+	}
+	v, err = m.GetMetric("hc-blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 1 {
+		t.Errorf("expected 1, but got %f", v)
+	}
+	if _, err := m.GetMetric("hc-empty"); err == nil {
+		t.Error("expected NoDataError")
+	}
+}
+
+func TestRoute53HealthCheckMetrics_GetMetric_ExactMatch(t *testing.T) {
+	client := &fakeRoute53HealthCheckClient{
+		records: []*route53.ResourceRecordSet{
+			{Name: aws.String("other.example.com."), Type: aws.String("A"), SetIdentifier: aws.String("green"), HealthCheckId: aws.String("hc-other")},
+			{Name: aws.String("example.com."), Type: aws.String("A"), SetIdentifier: aws.String("app-green"), HealthCheckId: aws.String("hc-app")},
+			{Name: aws.String("example.com."), Type: aws.String("TXT"), SetIdentifier: aws.String("green"), HealthCheckId: aws.String("hc-txt")},
+			{Name: aws.String("Example.com."), Type: aws.String("A"), SetIdentifier: aws.String("green"), HealthCheckId: aws.String("hc-green")},
+		},
+		statuses: map[string][]string{
+			"hc-other": {"Failure: Connection timed out"},
+			"hc-app":   {"Failure: Connection timed out"},
+			"hc-txt":   {"Failure: Connection timed out"},
+			"hc-green": {"Success: HTTP Status Code 200, OK"},
+		},
+	}
+	m, err := metrics.NewRoute53HealthCheckMetrics(&metrics.Route53HealthCheckConfig{
+		Client:        client,
+		HostedZoneId:  "Z1",
+		RecordName:    "example.com",
+		SetIdentifier: "green",
+		Type:          "A",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := m.GetMetric("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 1 {
+		t.Errorf("expected 1 from the health check of example.com. with the identifier green, but got %f", v)
+	}
+}
+
+func TestRoute53HealthCheckMetrics_GetMetric_NoHealthCheck(t *testing.T) {
+	client := &fakeRoute53HealthCheckClient{
+		records: []*route53.ResourceRecordSet{
+			{Name: aws.String("example.com."), SetIdentifier: aws.String("green")},
+		},
+	}
+	m, err := metrics.NewRoute53HealthCheckMetrics(&metrics.Route53HealthCheckConfig{
+		Client:        client,
+		HostedZoneId:  "Z1",
+		RecordName:    "example.com",
+		SetIdentifier: "green",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetMetric(""); err == nil {
+		t.Error("expected an error because the record set has no health check")
+	}
+}
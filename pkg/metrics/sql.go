@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	SQLMetricsType = "sql"
+
+	sqlDefaultTimeout = 30 * time.Second
+)
+
+type SQLConfig struct {
+	DB *sql.DB
+
+	// Driver is the name of a driver registered to database/sql, e.g. `postgres` or `mysql`.
+	Driver string
+	DSN    string
+
+	Timeout time.Duration
+}
+
+// SQLMetrics runs the query and reads a single numeric column from a single row.
+type SQLMetrics struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func (m *SQLMetrics) GetMetric(query string) (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return 0, &TimeoutError{query: query, timeout: m.timeout}
+		}
+		return 0, fmt.Errorf("failed to query sql: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query sql: %w", err)
+	}
+	if len(columns) != 1 {
+		return 0, fmt.Errorf("sql query must return a single column, but got %d columns", len(columns))
+	}
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, fmt.Errorf("failed to query sql: %w", err)
+		}
+		return 0, &NoDataError{query: query}
+	}
+	var value sql.NullFloat64
+	if err := rows.Scan(&value); err != nil {
+		return 0, fmt.Errorf("column `%s` of sql query is not a number: %w", columns[0], err)
+	}
+	if rows.Next() {
+		return 0, errors.New("sql query must return a single row, but got more than one row")
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query sql: %w", err)
+	}
+	if !value.Valid {
+		return 0, &NoDataError{query: query}
+	}
+
+	return value.Float64, nil
+}
+
+// Close closes the database.
+func (m *SQLMetrics) Close() error {
+	return m.db.Close()
+}
+
+func NewSQLMetrics(config *SQLConfig) (*SQLMetrics, error) {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = sqlDefaultTimeout
+	}
+
+	db := config.DB
+	if db == nil {
+		if config.Driver == "" {
+			return nil, fmt.Errorf("SQLConfig.Driver must be set when SQLConfig.DB is missing. available drivers are %s", strings.Join(sql.Drivers(), ", "))
+		}
+		if config.DSN == "" {
+			return nil, errors.New("SQLConfig.DSN must be set when SQLConfig.DB is missing")
+		}
+		d, err := sql.Open(config.Driver, config.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open sql database: %w", err)
+		}
+		db = d
+	}
+
+	return &SQLMetrics{
+		db:      db,
+		timeout: timeout,
+	}, nil
+}
@@ -0,0 +1,63 @@
+package metrics_test
+
+import (
+	"database/sql"
+	"github.com/k-kinzal/progressived/pkg/metrics"
+	_ "github.com/mattn/go-sqlite3"
+	"testing"
+)
+
+func newSQLMetrics(t *testing.T) *metrics.SQLMetrics {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	for _, q := range []string{
+		"CREATE TABLE payments (id INTEGER PRIMARY KEY, failed INTEGER)",
+		"INSERT INTO payments (failed) VALUES (0), (0), (1), (0)",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := metrics.NewSQLMetrics(&metrics.SQLConfig{DB: db})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestSQLMetrics_GetMetric(t *testing.T) {
+	m := newSQLMetrics(t)
+	defer m.Close()
+
+	v, err := m.GetMetric("SELECT 100.0 * SUM(failed) / COUNT(*) FROM payments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 25 {
+		t.Errorf("expected 25, but got %f", v)
+	}
+
+	for _, q := range []string{
+		"SELECT failed FROM payments WHERE id < 0",
+		"SELECT MAX(failed) FROM payments WHERE id < 0",
+	} {
+		if _, err := m.GetMetric(q); err == nil {
+			t.Errorf("expected NoDataError for `%s`", q)
+		} else if _, ok := err.(*metrics.NoDataError); !ok {
+			t.Errorf("expected NoDataError for `%s`, but got %v", q, err)
+		}
+	}
+
+	for _, q := range []string{
+		"SELECT failed FROM payments",
+		"SELECT id, failed FROM payments LIMIT 1",
+	} {
+		if _, err := m.GetMetric(q); err == nil {
+			t.Errorf("expected error for `%s`", q)
+		}
+	}
+}
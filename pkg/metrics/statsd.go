@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	statsdMaxPacketSize = 65535
+)
+
+// parseStatsDLine parses a StatsD line such as `http.errors:1|c|@0.1|#version:green`.
+// Counters are scaled by the sample rate and DogStatsD tags become labels.
+// A gauge value with a sign such as `-3|g` is a delta to the current value of the gauge.
+func parseStatsDLine(line string) (name string, labels map[string]string, value float64, delta bool, ok bool) {
+	i := strings.Index(line, ":")
+	if i <= 0 {
+		return "", nil, 0, false, false
+	}
+	name = line[:i]
+	fields := strings.Split(line[i+1:], "|")
+	if len(fields) < 2 {
+		return "", nil, 0, false, false
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", nil, 0, false, false
+	}
+	metricType := fields[1]
+	delta = metricType == "g" && (strings.HasPrefix(fields[0], "+") || strings.HasPrefix(fields[0], "-"))
+	if metricType == "s" {
+		// sets count unique values, which cannot be kept as samples
+		return "", nil, 0, false, false
+	}
+
+	labels = make(map[string]string)
+	for _, f := range fields[2:] {
+		switch {
+		case strings.HasPrefix(f, "@"):
+			rate, err := strconv.ParseFloat(f[1:], 64)
+			if err == nil && rate > 0 && metricType == "c" {
+				value /= rate
+			}
+		case strings.HasPrefix(f, "#"):
+			for _, tag := range strings.Split(f[1:], ",") {
+				kv := strings.SplitN(tag, ":", 2)
+				if len(kv) == 2 {
+					labels[kv[0]] = kv[1]
+				} else {
+					labels[kv[0]] = ""
+				}
+			}
+		}
+	}
+
+	return name, labels, value, delta, true
+}
+
+func listenStatsD(address string, store *pushStore) (net.PacketConn, error) {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen StatsD: %w", err)
+	}
+
+	go func() {
+		buf := make([]byte, statsdMaxPacketSize)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				// the connection is closed
+				return
+			}
+			now := time.Now()
+			for _, line := range strings.Split(string(buf[:n]), "\n") {
+				name, labels, value, delta, ok := parseStatsDLine(strings.TrimSpace(line))
+				if !ok {
+					continue
+				}
+				if delta {
+					store.addDelta(name, labels, now, value)
+				} else {
+					store.add(name, labels, now, value)
+				}
+			}
+		}
+	}()
+
+	return conn, nil
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// TLSConfig is the TLS configuration of metrics that talk to an HTTPS endpoint.
+type TLSConfig struct {
+	// CAFile is a PEM file of the certificate authorities that verify the server.
+	CAFile string
+	// CertFile and KeyFile are a PEM client certificate and key.
+	CertFile string
+	KeyFile  string
+
+	InsecureSkipVerify bool
+}
+
+func newHTTPClient(config *TLSConfig, timeout time.Duration) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config != nil {
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: config.InsecureSkipVerify,
+		}
+		if config.CAFile != "" {
+			b, err := ioutil.ReadFile(config.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(b) {
+				return nil, fmt.Errorf("no certificate was found in `%s`", config.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if config.CertFile != "" || config.KeyFile != "" {
+			cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
@@ -1,5 +1,7 @@
 package progressived
 
+import "github.com/k-kinzal/progressived/pkg/provider"
+
 func (p *Progressived) TargetName() string {
 	return p.Provider.TargetName()
 }
@@ -17,14 +19,7 @@ func (p *Progressived) NextPercentage() (float64, error) {
 	if err != nil {
 		return -1, err
 	}
-	npct := p.Algorithm.Next(pct)
-	if npct <= 0 {
-		npct = 0
-	}
-	if npct >= 100 {
-		npct = 100
-	}
-	return npct, nil
+	return p.normalizePercentage(pct, p.Algorithm.Next(pct)), nil
 }
 
 func (p *Progressived) PreviousPercentage() (float64, error) {
@@ -32,12 +27,22 @@ func (p *Progressived) PreviousPercentage() (float64, error) {
 	if err != nil {
 		return -1, err
 	}
-	ppct := p.Algorithm.Previous(pct)
-	if ppct <= 0 {
-		ppct = 0
+	return p.normalizePercentage(pct, p.Algorithm.Previous(pct)), nil
+}
+
+// normalizePercentage clamps the percentage to [0, 100] and rounds it to a
+// value the provider can express, so that it matches what Get returns after Update.
+// It moves at least one weight unit from current, so that a small step is not
+// mistaken for completion.
+func (p *Progressived) normalizePercentage(current float64, pct float64) float64 {
+	if pct <= 0 {
+		pct = 0
+	}
+	if pct >= 100 {
+		pct = 100
 	}
-	if ppct >= 100 {
-		ppct = 100
+	if wp, ok := p.Provider.(provider.WeightedProvider); ok {
+		pct = provider.QuantizeStep(current, pct, wp.TotalWeight())
 	}
-	return ppct, nil
+	return pct
 }
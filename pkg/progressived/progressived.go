@@ -9,13 +9,19 @@ import (
 )
 
 type Progressived struct {
-	Provider  provider.Provider
-	Metrics   metrics.Metrics
+	Provider provider.Provider
+	Metrics  metrics.Metrics
+	// Guard is checked before Metrics if set. Any value other than zero fails
+	// the update, e.g. the number of CloudWatch alarms in ALARM state.
+	Guard     metrics.Metrics
 	Builder   *metrics.QueryBuilder
 	Algorithm algorithm.Algorithm
 	Formura   *formura.Formula
 
 	AllowNoData bool
+	// RequireAllDatapoints requires every datapoint in the collection period to
+	// match the condition instead of the reduced value. Metrics must implement metrics.SeriesMetrics.
+	RequireAllDatapoints bool
 }
 
 type NotMatchMetricsError struct {
@@ -5,25 +5,65 @@ import (
 	"github.com/k-kinzal/progressived/pkg/metrics"
 )
 
+func (p *Progressived) metricValues(query string) ([]float64, error) {
+	if !p.RequireAllDatapoints {
+		value, err := p.Metrics.GetMetric(query)
+		if err != nil {
+			return nil, err
+		}
+		return []float64{value}, nil
+	}
+
+	m, ok := p.Metrics.(metrics.SeriesMetrics)
+	if !ok {
+		return nil, fmt.Errorf("metrics %T does not support evaluating all datapoints", p.Metrics)
+	}
+	return m.GetMetricSeries(query)
+}
+
+func (p *Progressived) checkGuard() error {
+	if p.Guard == nil {
+		return nil
+	}
+	value, err := p.Guard.GetMetric("")
+	if err != nil {
+		if _, ok := err.(*metrics.NoDataError); ok && p.AllowNoData {
+			return nil
+		}
+		return err
+	}
+	if value != 0 {
+		return NotMatchMetricsError{value, metrics.CloudWatchAlarmCondition}
+	}
+	return nil
+}
+
 func (p *Progressived) Update() (float64, error) {
+	if err := p.checkGuard(); err != nil {
+		if _, ok := err.(NotMatchMetricsError); ok {
+			return -1, err
+		}
+		return -1, fmt.Errorf("update: %w", err)
+	}
+
 	query, err := p.Builder.Build(nil)
 	if err != nil {
 		return -1, fmt.Errorf("update: %w", err)
 	}
 
-	value, err := p.Metrics.GetMetric(query)
+	values, err := p.metricValues(query)
 	if err != nil {
 		if _, ok := err.(*metrics.NoDataError); !ok || !p.AllowNoData {
 			return -1, fmt.Errorf("update: %w", err)
 		}
 	}
-	if _, ok := err.(*metrics.NoDataError); !ok {
+	for _, value := range values {
 		ok, err := p.Formura.Eval(value)
 		if err != nil {
 			return -1, fmt.Errorf("update: %w", err)
 		}
 		if !ok {
-			return -1, &NotMatchMetricsError{value, p.Formura.Expression()}
+			return -1, NotMatchMetricsError{value, p.Formura.Expression()}
 		}
 	}
 
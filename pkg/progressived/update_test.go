@@ -0,0 +1,133 @@
+package progressived_test
+
+import (
+	"github.com/k-kinzal/progressived/pkg/algorithm"
+	"github.com/k-kinzal/progressived/pkg/formura"
+	"github.com/k-kinzal/progressived/pkg/metrics"
+	"github.com/k-kinzal/progressived/pkg/progressived"
+	"testing"
+)
+
+type fakeProvider struct {
+	percentage float64
+}
+
+func (p *fakeProvider) TargetName() string {
+	return "fake"
+}
+
+func (p *fakeProvider) Get() (float64, error) {
+	return p.percentage, nil
+}
+
+func (p *fakeProvider) Update(percentage float64) error {
+	p.percentage = percentage
+	return nil
+}
+
+// fakeWeightedProvider can only express whole percentages.
+type fakeWeightedProvider struct {
+	fakeProvider
+}
+
+func (p *fakeWeightedProvider) MaxWeight() int64 {
+	return 100
+}
+
+func (p *fakeWeightedProvider) TotalWeight() int64 {
+	return 100
+}
+
+type fakeMetrics struct {
+	value float64
+	err   error
+}
+
+func (m *fakeMetrics) GetMetric(query string) (float64, error) {
+	return m.value, m.err
+}
+
+func newProgressived(prov *fakeProvider, met metrics.Metrics, condition string) *progressived.Progressived {
+	return &progressived.Progressived{
+		Provider:  prov,
+		Metrics:   met,
+		Builder:   metrics.NewQueryBuikder("", map[string]interface{}{}),
+		Algorithm: algorithm.NewIncretion(10),
+		Formura:   formura.NewFormula(condition),
+	}
+}
+
+func TestProgressived_Update_NotMatchMetrics(t *testing.T) {
+	prov := &fakeProvider{percentage: 20}
+	p := newProgressived(prov, &fakeMetrics{value: 5}, "x < 1")
+
+	_, err := p.Update()
+	// the controller rolls back when the error is a NotMatchMetricsError value
+	if _, ok := err.(progressived.NotMatchMetricsError); !ok {
+		t.Fatalf("expected NotMatchMetricsError, but got %#v", err)
+	}
+	if prov.percentage != 20 {
+		t.Errorf("expected the percentage not to be updated, but got %f", prov.percentage)
+	}
+}
+
+func TestProgressived_Update(t *testing.T) {
+	prov := &fakeProvider{percentage: 20}
+	p := newProgressived(prov, &fakeMetrics{value: 0}, "x < 1")
+
+	v, err := p.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 30 || prov.percentage != 30 {
+		t.Errorf("expected 30, but got %f, %f", v, prov.percentage)
+	}
+}
+
+func TestProgressived_Update_StepSmallerThanWeight(t *testing.T) {
+	prov := &fakeWeightedProvider{fakeProvider{percentage: 20}}
+	p := &progressived.Progressived{
+		Provider:  prov,
+		Metrics:   &fakeMetrics{value: 0},
+		Builder:   metrics.NewQueryBuikder("", map[string]interface{}{}),
+		Algorithm: algorithm.NewIncretion(0.3),
+		Formura:   formura.NewFormula("x < 1"),
+	}
+
+	v, err := p.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 21 || prov.percentage != 21 {
+		t.Errorf("expected 21, but got %f, %f", v, prov.percentage)
+	}
+}
+
+func TestProgressived_Update_Guard(t *testing.T) {
+	cases := []struct {
+		name        string
+		guard       *fakeMetrics
+		allowNoData bool
+		notMatch    bool
+		updated     bool
+	}{
+		{"no alarm", &fakeMetrics{value: 0}, false, false, true},
+		{"alarm", &fakeMetrics{value: 2}, false, true, false},
+		{"no data", &fakeMetrics{err: &metrics.NoDataError{}}, false, false, false},
+		{"allow no data", &fakeMetrics{err: &metrics.NoDataError{}}, true, false, true},
+	}
+	for _, c := range cases {
+		prov := &fakeProvider{percentage: 20}
+		p := newProgressived(prov, &fakeMetrics{value: 0}, "x < 1")
+		p.Guard = c.guard
+		p.AllowNoData = c.allowNoData
+
+		_, err := p.Update()
+		if _, ok := err.(progressived.NotMatchMetricsError); ok != c.notMatch {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+		if updated := prov.percentage != 20; updated != c.updated {
+			t.Errorf("%s: expected updated to be %t, but got %f, %v", c.name, c.updated, prov.percentage, err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/appmesh"
+)
+
+const (
+	AppMeshProviderType = "appmesh"
+
+	// AppMeshMaxWeight is the largest weight App Mesh accepts for a weighted target.
+	AppMeshMaxWeight = 100
+	// AppMeshDefaultTotalWeight is used when AppMeshConfig.TotalWeight is not set.
+	AppMeshDefaultTotalWeight = 100
+)
+
+type AppMeshConfig struct {
+	Sess *session.Session
+
+	Client AppMeshClient
+
+	MeshName string
+	// MeshOwner is the AWS account ID of the mesh owner if the mesh is shared.
+	MeshOwner         string
+	VirtualRouterName string
+	RouteName         string
+	// SourceVirtualNode and DestinationVirtualNode are the virtual nodes of the weighted targets.
+	SourceVirtualNode      string
+	DestinationVirtualNode string
+
+	// TotalWeight is the sum of the source and destination weights.
+	TotalWeight int64
+}
+
+type AppMeshClient interface {
+	DescribeRoute(input *appmesh.DescribeRouteInput) (*appmesh.DescribeRouteOutput, error)
+	UpdateRoute(input *appmesh.UpdateRouteInput) (*appmesh.UpdateRouteOutput, error)
+}
+
+// AppMeshProvider shifts the weight between two virtual nodes in the weighted targets of an App Mesh route.
+type AppMeshProvider struct {
+	client AppMeshClient
+	config *AppMeshConfig
+}
+
+func (p *AppMeshProvider) TargetName() string {
+	return fmt.Sprintf("AWS/AppMesh/%s/%s/%s", p.config.MeshName, p.config.VirtualRouterName, p.config.RouteName)
+}
+
+func (p *AppMeshProvider) MaxWeight() int64 {
+	return AppMeshMaxWeight
+}
+
+func (p *AppMeshProvider) TotalWeight() int64 {
+	return p.config.TotalWeight
+}
+
+// appMeshWeightedTargets returns the weighted targets of the route, whichever protocol it is for.
+func appMeshWeightedTargets(spec *appmesh.RouteSpec) []*appmesh.WeightedTarget {
+	switch {
+	case spec == nil:
+		return nil
+	case spec.HttpRoute != nil && spec.HttpRoute.Action != nil:
+		return spec.HttpRoute.Action.WeightedTargets
+	case spec.Http2Route != nil && spec.Http2Route.Action != nil:
+		return spec.Http2Route.Action.WeightedTargets
+	case spec.GrpcRoute != nil && spec.GrpcRoute.Action != nil:
+		return spec.GrpcRoute.Action.WeightedTargets
+	case spec.TcpRoute != nil && spec.TcpRoute.Action != nil:
+		return spec.TcpRoute.Action.WeightedTargets
+	}
+	return nil
+}
+
+func (p *AppMeshProvider) describeRoute() (*appmesh.RouteSpec, error) {
+	input := &appmesh.DescribeRouteInput{
+		MeshName:          aws.String(p.config.MeshName),
+		VirtualRouterName: aws.String(p.config.VirtualRouterName),
+		RouteName:         aws.String(p.config.RouteName),
+	}
+	if p.config.MeshOwner != "" {
+		input.MeshOwner = aws.String(p.config.MeshOwner)
+	}
+	res, err := p.client.DescribeRoute(input)
+	if err != nil {
+		return nil, err
+	}
+	if res.Route == nil || res.Route.Spec == nil {
+		return nil, fmt.Errorf("route `%s` has no spec", p.config.RouteName)
+	}
+	return res.Route.Spec, nil
+}
+
+func (p *AppMeshProvider) findWeightedTargets(spec *appmesh.RouteSpec) (src *appmesh.WeightedTarget, dest *appmesh.WeightedTarget, err error) {
+	for _, t := range appMeshWeightedTargets(spec) {
+		switch aws.StringValue(t.VirtualNode) {
+		case p.config.SourceVirtualNode:
+			src = t
+		case p.config.DestinationVirtualNode:
+			dest = t
+		}
+	}
+	if src == nil || dest == nil {
+		return nil, nil, fmt.Errorf("weighted targets for the virtual nodes `%s` and `%s` were not found in route `%s`", p.config.SourceVirtualNode, p.config.DestinationVirtualNode, p.config.RouteName)
+	}
+	return src, dest, nil
+}
+
+func (p *AppMeshProvider) Get() (float64, error) {
+	spec, err := p.describeRoute()
+	if err != nil {
+		return -1, err
+	}
+	src, dest, err := p.findWeightedTargets(spec)
+	if err != nil {
+		return -1, err
+	}
+
+	return WeightPercentage(aws.Int64Value(src.Weight), aws.Int64Value(dest.Weight)), nil
+}
+
+func (p *AppMeshProvider) Update(percentage float64) error {
+	spec, err := p.describeRoute()
+	if err != nil {
+		return err
+	}
+	src, dest, err := p.findWeightedTargets(spec)
+	if err != nil {
+		return err
+	}
+
+	sourceWeight, destinationWeight := DistributeWeight(percentage, p.config.TotalWeight)
+	src.Weight = aws.Int64(sourceWeight)
+	dest.Weight = aws.Int64(destinationWeight)
+
+	// the whole spec is sent back, so that the match and the retry policy of the route are kept
+	input := &appmesh.UpdateRouteInput{
+		MeshName:          aws.String(p.config.MeshName),
+		VirtualRouterName: aws.String(p.config.VirtualRouterName),
+		RouteName:         aws.String(p.config.RouteName),
+		Spec:              spec,
+	}
+	if p.config.MeshOwner != "" {
+		input.MeshOwner = aws.String(p.config.MeshOwner)
+	}
+	if _, err := p.client.UpdateRoute(input); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func NewAppMeshProvider(config *AppMeshConfig) (*AppMeshProvider, error) {
+	if config.MeshName == "" {
+		return nil, errors.New("AppMeshConfig.MeshName is missing")
+	}
+	if config.VirtualRouterName == "" {
+		return nil, errors.New("AppMeshConfig.VirtualRouterName is missing")
+	}
+	if config.RouteName == "" {
+		return nil, errors.New("AppMeshConfig.RouteName is missing")
+	}
+	if config.SourceVirtualNode == "" {
+		return nil, errors.New("AppMeshConfig.SourceVirtualNode is missing")
+	}
+	if config.DestinationVirtualNode == "" {
+		return nil, errors.New("AppMeshConfig.DestinationVirtualNode is missing")
+	}
+	if config.SourceVirtualNode == config.DestinationVirtualNode {
+		return nil, errors.New("AppMeshConfig.SourceVirtualNode and AppMeshConfig.DestinationVirtualNode must be different")
+	}
+	if config.TotalWeight == 0 {
+		config.TotalWeight = AppMeshDefaultTotalWeight
+	}
+	if err := validateTotalWeight("AppMeshConfig.TotalWeight", config.TotalWeight, AppMeshMaxWeight); err != nil {
+		return nil, err
+	}
+
+	client := config.Client
+
+	if client == nil {
+		if config.Sess == nil {
+			return nil, errors.New("AppMeshConfig.Sess must be set when AppMeshConfig.Client is missing")
+		}
+		client = appmesh.New(config.Sess)
+	}
+
+	return &AppMeshProvider{
+		client: client,
+		config: config,
+	}, nil
+}
@@ -0,0 +1,90 @@
+package provider_test
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/appmesh"
+	"github.com/k-kinzal/progressived/pkg/provider"
+	"testing"
+)
+
+type fakeAppMeshClient struct {
+	spec    *appmesh.RouteSpec
+	updates int
+}
+
+func (c *fakeAppMeshClient) DescribeRoute(input *appmesh.DescribeRouteInput) (*appmesh.DescribeRouteOutput, error) {
+	return &appmesh.DescribeRouteOutput{Route: &appmesh.RouteData{Spec: c.spec}}, nil
+}
+
+func (c *fakeAppMeshClient) UpdateRoute(input *appmesh.UpdateRouteInput) (*appmesh.UpdateRouteOutput, error) {
+	c.spec = input.Spec
+	c.updates++
+	return &appmesh.UpdateRouteOutput{Route: &appmesh.RouteData{Spec: c.spec}}, nil
+}
+
+func TestAppMeshProvider_Update(t *testing.T) {
+	client := &fakeAppMeshClient{
+		spec: &appmesh.RouteSpec{
+			HttpRoute: &appmesh.HttpRoute{
+				Match: &appmesh.HttpRouteMatch{Prefix: aws.String("/")},
+				Action: &appmesh.HttpRouteAction{
+					WeightedTargets: []*appmesh.WeightedTarget{
+						{VirtualNode: aws.String("web-blue"), Weight: aws.Int64(100)},
+						{VirtualNode: aws.String("web-green"), Weight: aws.Int64(0)},
+					},
+				},
+			},
+		},
+	}
+	p, err := provider.NewAppMeshProvider(&provider.AppMeshConfig{
+		Client:                 client,
+		MeshName:               "mesh",
+		VirtualRouterName:      "web",
+		RouteName:              "web-route",
+		SourceVirtualNode:      "web-blue",
+		DestinationVirtualNode: "web-green",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(40); err != nil {
+		t.Fatal(err)
+	}
+	if client.updates != 1 {
+		t.Errorf("expected the route to be updated once, but it was updated %d times", client.updates)
+	}
+	if aws.StringValue(client.spec.HttpRoute.Match.Prefix) != "/" {
+		t.Errorf("expected the match to be kept, but got %v", client.spec.HttpRoute.Match)
+	}
+	if v, err := p.Get(); err != nil || v != 40 {
+		t.Errorf("expected 40, but got %f, %v", v, err)
+	}
+}
+
+func TestAppMeshProvider_Get_NotFound(t *testing.T) {
+	client := &fakeAppMeshClient{
+		spec: &appmesh.RouteSpec{
+			TcpRoute: &appmesh.TcpRoute{
+				Action: &appmesh.TcpRouteAction{
+					WeightedTargets: []*appmesh.WeightedTarget{
+						{VirtualNode: aws.String("web-blue"), Weight: aws.Int64(1)},
+					},
+				},
+			},
+		},
+	}
+	p, err := provider.NewAppMeshProvider(&provider.AppMeshConfig{
+		Client:                 client,
+		MeshName:               "mesh",
+		VirtualRouterName:      "web",
+		RouteName:              "web-route",
+		SourceVirtualNode:      "web-blue",
+		DestinationVirtualNode: "web-green",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Get(); err == nil {
+		t.Error("expected an error because the destination virtual node is missing")
+	}
+}
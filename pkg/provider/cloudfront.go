@@ -0,0 +1,220 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+	"math"
+)
+
+const (
+	CloudFrontProviderType = "cloudfront"
+
+	// CloudFrontMaxStagingWeight is the largest percentage of traffic CloudFront routes to the staging distribution.
+	CloudFrontMaxStagingWeight = 15.0
+)
+
+type CloudFrontConfig struct {
+	Sess *session.Session
+
+	Client CloudFrontClient
+
+	PrimaryDistributionId string
+	StagingDistributionId string
+	// ContinuousDeploymentPolicyId defaults to the policy attached to the primary distribution.
+	// The primary distribution without a policy attached is regarded as promoted.
+	ContinuousDeploymentPolicyId string
+
+	// MaxStagingWeight is the largest percentage routed to the staging distribution.
+	// A percentage above it promotes the staging distribution. Defaults to CloudFrontMaxStagingWeight.
+	MaxStagingWeight float64
+}
+
+type CloudFrontClient interface {
+	GetDistributionConfig(input *cloudfront.GetDistributionConfigInput) (*cloudfront.GetDistributionConfigOutput, error)
+	UpdateDistribution(input *cloudfront.UpdateDistributionInput) (*cloudfront.UpdateDistributionOutput, error)
+	UpdateDistributionWithStagingConfig(input *cloudfront.UpdateDistributionWithStagingConfigInput) (*cloudfront.UpdateDistributionWithStagingConfigOutput, error)
+	GetContinuousDeploymentPolicy(input *cloudfront.GetContinuousDeploymentPolicyInput) (*cloudfront.GetContinuousDeploymentPolicyOutput, error)
+	UpdateContinuousDeploymentPolicy(input *cloudfront.UpdateContinuousDeploymentPolicyInput) (*cloudfront.UpdateContinuousDeploymentPolicyOutput, error)
+}
+
+// CloudFrontProvider shifts traffic to the staging distribution with the weight of a
+// continuous deployment policy. CloudFront cannot route more than the max staging weight
+// to it, so a percentage above that promotes the staging distribution to the primary and
+// Get returns 100 after it. The promotion detaches the policy from the primary distribution,
+// which is how Get tells that it was promoted.
+type CloudFrontProvider struct {
+	client CloudFrontClient
+	config *CloudFrontConfig
+}
+
+func (p *CloudFrontProvider) TargetName() string {
+	return fmt.Sprintf("AWS/CloudFront/%s", p.config.PrimaryDistributionId)
+}
+
+// attachedPolicyId returns the continuous deployment policy attached to the primary distribution,
+// which is empty once the staging distribution was promoted.
+func (p *CloudFrontProvider) attachedPolicyId() (string, error) {
+	res, err := p.client.GetDistributionConfig(&cloudfront.GetDistributionConfigInput{
+		Id: aws.String(p.config.PrimaryDistributionId),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(res.DistributionConfig.ContinuousDeploymentPolicyId), nil
+}
+
+func (p *CloudFrontProvider) getPolicy(attachedId string) (string, *cloudfront.GetContinuousDeploymentPolicyOutput, error) {
+	id := p.config.ContinuousDeploymentPolicyId
+	if id == "" {
+		id = attachedId
+	}
+	res, err := p.client.GetContinuousDeploymentPolicy(&cloudfront.GetContinuousDeploymentPolicyInput{
+		Id: aws.String(id),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	policy := res.ContinuousDeploymentPolicy
+	if policy == nil || policy.ContinuousDeploymentPolicyConfig == nil {
+		return "", nil, fmt.Errorf("continuous deployment policy `%s` has no config", id)
+	}
+	traffic := policy.ContinuousDeploymentPolicyConfig.TrafficConfig
+	if traffic == nil || aws.StringValue(traffic.Type) != cloudfront.ContinuousDeploymentPolicyTypeSingleWeight || traffic.SingleWeightConfig == nil {
+		return "", nil, fmt.Errorf("continuous deployment policy `%s` is not weight-based", id)
+	}
+	return id, res, nil
+}
+
+func (p *CloudFrontProvider) Get() (float64, error) {
+	attachedId, err := p.attachedPolicyId()
+	if err != nil {
+		return -1, err
+	}
+	if attachedId == "" {
+		return 100, nil
+	}
+	_, res, err := p.getPolicy(attachedId)
+	if err != nil {
+		return -1, err
+	}
+	config := res.ContinuousDeploymentPolicy.ContinuousDeploymentPolicyConfig
+	if !aws.BoolValue(config.Enabled) {
+		return 0, nil
+	}
+
+	// the weight is a fraction, e.g. 0.05 for 5%
+	return math.Round(aws.Float64Value(config.TrafficConfig.SingleWeightConfig.Weight)*10000) / 100, nil
+}
+
+// promote copies the staging distribution to the primary and then detaches the policy from the primary.
+func (p *CloudFrontProvider) promote() error {
+	primary, err := p.client.GetDistributionConfig(&cloudfront.GetDistributionConfigInput{
+		Id: aws.String(p.config.PrimaryDistributionId),
+	})
+	if err != nil {
+		return err
+	}
+	staging, err := p.client.GetDistributionConfig(&cloudfront.GetDistributionConfigInput{
+		Id: aws.String(p.config.StagingDistributionId),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.client.UpdateDistributionWithStagingConfig(&cloudfront.UpdateDistributionWithStagingConfigInput{
+		Id:                    aws.String(p.config.PrimaryDistributionId),
+		StagingDistributionId: aws.String(p.config.StagingDistributionId),
+		IfMatch:               aws.String(fmt.Sprintf("%s, %s", aws.StringValue(primary.ETag), aws.StringValue(staging.ETag))),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to promote staging distribution `%s`: %w", p.config.StagingDistributionId, err)
+	}
+
+	// the primary keeps the policy through the promotion, so it is read again with the new ETag
+	primary, err = p.client.GetDistributionConfig(&cloudfront.GetDistributionConfigInput{
+		Id: aws.String(p.config.PrimaryDistributionId),
+	})
+	if err != nil {
+		return err
+	}
+	primary.DistributionConfig.ContinuousDeploymentPolicyId = aws.String("")
+	_, err = p.client.UpdateDistribution(&cloudfront.UpdateDistributionInput{
+		Id:                 aws.String(p.config.PrimaryDistributionId),
+		IfMatch:            primary.ETag,
+		DistributionConfig: primary.DistributionConfig,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to detach the continuous deployment policy from distribution `%s`: %w", p.config.PrimaryDistributionId, err)
+	}
+	return nil
+}
+
+func (p *CloudFrontProvider) Update(percentage float64) error {
+	attachedId, err := p.attachedPolicyId()
+	if err != nil {
+		return err
+	}
+	if attachedId == "" {
+		if percentage > p.config.MaxStagingWeight {
+			return nil
+		}
+		return fmt.Errorf("staging distribution `%s` was already promoted to `%s`", p.config.StagingDistributionId, p.config.PrimaryDistributionId)
+	}
+	if percentage > p.config.MaxStagingWeight {
+		return p.promote()
+	}
+	if percentage < 0 {
+		percentage = 0
+	}
+
+	id, res, err := p.getPolicy(attachedId)
+	if err != nil {
+		return err
+	}
+	config := res.ContinuousDeploymentPolicy.ContinuousDeploymentPolicyConfig
+	config.Enabled = aws.Bool(true)
+	config.TrafficConfig.SingleWeightConfig.Weight = aws.Float64(math.Round(percentage*100) / 10000)
+
+	input := &cloudfront.UpdateContinuousDeploymentPolicyInput{
+		Id:                               aws.String(id),
+		IfMatch:                          res.ETag,
+		ContinuousDeploymentPolicyConfig: config,
+	}
+	if _, err := p.client.UpdateContinuousDeploymentPolicy(input); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func NewCloudFrontProvider(config *CloudFrontConfig) (*CloudFrontProvider, error) {
+	if config.PrimaryDistributionId == "" {
+		return nil, errors.New("CloudFrontConfig.PrimaryDistributionId is missing")
+	}
+	if config.StagingDistributionId == "" {
+		return nil, errors.New("CloudFrontConfig.StagingDistributionId is missing")
+	}
+	if config.MaxStagingWeight == 0 {
+		config.MaxStagingWeight = CloudFrontMaxStagingWeight
+	}
+	if config.MaxStagingWeight < 0 || config.MaxStagingWeight > CloudFrontMaxStagingWeight {
+		return nil, fmt.Errorf("CloudFrontConfig.MaxStagingWeight must be between 0 and %g", CloudFrontMaxStagingWeight)
+	}
+
+	client := config.Client
+
+	if client == nil {
+		if config.Sess == nil {
+			return nil, errors.New("CloudFrontConfig.Sess must be set when CloudFrontConfig.Client is missing")
+		}
+		client = cloudfront.New(config.Sess)
+	}
+
+	return &CloudFrontProvider{
+		client: client,
+		config: config,
+	}, nil
+}
@@ -0,0 +1,202 @@
+package provider_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+	"github.com/k-kinzal/progressived/pkg/algorithm"
+	"github.com/k-kinzal/progressived/pkg/formura"
+	"github.com/k-kinzal/progressived/pkg/metrics"
+	"github.com/k-kinzal/progressived/pkg/progressived"
+	"github.com/k-kinzal/progressived/pkg/provider"
+	"testing"
+)
+
+type fakeCloudFrontClient struct {
+	policy *cloudfront.ContinuousDeploymentPolicyConfig
+	// attached is the policy attached to the primary distribution
+	attached string
+	// version of the primary distribution, which is its ETag
+	version  int
+	ifMatch  string
+	promoted string
+}
+
+func (c *fakeCloudFrontClient) GetDistributionConfig(input *cloudfront.GetDistributionConfigInput) (*cloudfront.GetDistributionConfigOutput, error) {
+	config := &cloudfront.DistributionConfig{}
+	etag := "etag-" + aws.StringValue(input.Id)
+	if aws.StringValue(input.Id) == "EPRIMARY" {
+		config.ContinuousDeploymentPolicyId = aws.String(c.attached)
+		etag = fmt.Sprintf("%s-%d", etag, c.version)
+	}
+	return &cloudfront.GetDistributionConfigOutput{
+		DistributionConfig: config,
+		ETag:               aws.String(etag),
+	}, nil
+}
+
+func (c *fakeCloudFrontClient) UpdateDistribution(input *cloudfront.UpdateDistributionInput) (*cloudfront.UpdateDistributionOutput, error) {
+	if aws.StringValue(input.IfMatch) != fmt.Sprintf("etag-EPRIMARY-%d", c.version) {
+		return nil, errors.New("PreconditionFailed")
+	}
+	c.attached = aws.StringValue(input.DistributionConfig.ContinuousDeploymentPolicyId)
+	c.version++
+	return &cloudfront.UpdateDistributionOutput{}, nil
+}
+
+func (c *fakeCloudFrontClient) UpdateDistributionWithStagingConfig(input *cloudfront.UpdateDistributionWithStagingConfigInput) (*cloudfront.UpdateDistributionWithStagingConfigOutput, error) {
+	c.promoted = aws.StringValue(input.StagingDistributionId)
+	c.ifMatch = aws.StringValue(input.IfMatch)
+	c.version++
+	return &cloudfront.UpdateDistributionWithStagingConfigOutput{}, nil
+}
+
+func (c *fakeCloudFrontClient) GetContinuousDeploymentPolicy(input *cloudfront.GetContinuousDeploymentPolicyInput) (*cloudfront.GetContinuousDeploymentPolicyOutput, error) {
+	return &cloudfront.GetContinuousDeploymentPolicyOutput{
+		ContinuousDeploymentPolicy: &cloudfront.ContinuousDeploymentPolicy{
+			Id:                               input.Id,
+			ContinuousDeploymentPolicyConfig: c.policy,
+		},
+		ETag: aws.String("etag-policy"),
+	}, nil
+}
+
+func (c *fakeCloudFrontClient) UpdateContinuousDeploymentPolicy(input *cloudfront.UpdateContinuousDeploymentPolicyInput) (*cloudfront.UpdateContinuousDeploymentPolicyOutput, error) {
+	c.policy = input.ContinuousDeploymentPolicyConfig
+	c.ifMatch = aws.StringValue(input.IfMatch)
+	return &cloudfront.UpdateContinuousDeploymentPolicyOutput{}, nil
+}
+
+// newFakeCloudFrontClient returns a client whose primary distribution has a disabled policy attached.
+func newFakeCloudFrontClient() *fakeCloudFrontClient {
+	return &fakeCloudFrontClient{
+		attached: "policy",
+		policy: &cloudfront.ContinuousDeploymentPolicyConfig{
+			Enabled: aws.Bool(false),
+			StagingDistributionDnsNames: &cloudfront.StagingDistributionDnsNames{
+				Items:    []*string{aws.String("d111111abcdef8.cloudfront.net")},
+				Quantity: aws.Int64(1),
+			},
+			TrafficConfig: &cloudfront.TrafficConfig{
+				Type: aws.String(cloudfront.ContinuousDeploymentPolicyTypeSingleWeight),
+				SingleWeightConfig: &cloudfront.ContinuousDeploymentSingleWeightConfig{
+					Weight: aws.Float64(0),
+				},
+			},
+		},
+	}
+}
+
+func TestCloudFrontProvider_Update(t *testing.T) {
+	client := newFakeCloudFrontClient()
+	p, err := provider.NewCloudFrontProvider(&provider.CloudFrontConfig{
+		Client:                client,
+		PrimaryDistributionId: "EPRIMARY",
+		StagingDistributionId: "ESTAGING",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := p.Get(); err != nil || v != 0 {
+		t.Errorf("expected 0, but got %f, %v", v, err)
+	}
+
+	if err := p.Update(7.5); err != nil {
+		t.Fatal(err)
+	}
+	if w := aws.Float64Value(client.policy.TrafficConfig.SingleWeightConfig.Weight); w != 0.075 {
+		t.Errorf("expected the weight to be 0.075, but got %f", w)
+	}
+	if !aws.BoolValue(client.policy.Enabled) || client.ifMatch != "etag-policy" {
+		t.Errorf("expected the policy to be enabled with the ETag, but got %v, %s", client.policy.Enabled, client.ifMatch)
+	}
+	if v, err := p.Get(); err != nil || v != 7.5 {
+		t.Errorf("expected 7.5, but got %f, %v", v, err)
+	}
+
+	if err := p.Update(15); err != nil {
+		t.Fatal(err)
+	}
+	if w := aws.Float64Value(client.policy.TrafficConfig.SingleWeightConfig.Weight); w != 0.15 {
+		t.Errorf("expected the weight to be 0.15, but got %f", w)
+	}
+	if client.promoted != "" {
+		t.Errorf("expected the staging distribution not to be promoted, but got %s", client.promoted)
+	}
+	if v, err := p.Get(); err != nil || v != 15 {
+		t.Errorf("expected 15, but got %f, %v", v, err)
+	}
+
+	// CloudFront cannot route more than 15% to the staging distribution, so a percentage above it
+	// promotes the staging distribution and detaches the policy
+	if err := p.Update(20); err != nil {
+		t.Fatal(err)
+	}
+	if client.promoted != "ESTAGING" || client.ifMatch != "etag-EPRIMARY-0, etag-ESTAGING" {
+		t.Errorf("expected the staging distribution to be promoted, but got %s, %s", client.promoted, client.ifMatch)
+	}
+	if client.attached != "" {
+		t.Errorf("expected the policy to be detached, but got %s", client.attached)
+	}
+
+	// the promotion is read from CloudFront rather than kept in the provider
+	p, err = provider.NewCloudFrontProvider(&provider.CloudFrontConfig{
+		Client:                client,
+		PrimaryDistributionId: "EPRIMARY",
+		StagingDistributionId: "ESTAGING",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := p.Get(); err != nil || v != 100 {
+		t.Errorf("expected 100, but got %f, %v", v, err)
+	}
+	if err := p.Update(100); err != nil {
+		t.Errorf("expected no error because the staging distribution was already promoted, but got %v", err)
+	}
+	if err := p.Update(0); err == nil {
+		t.Error("expected an error because the promotion cannot be rolled back")
+	}
+}
+
+func TestCloudFrontProvider_Progressived(t *testing.T) {
+	client := newFakeCloudFrontClient()
+	prov, err := provider.NewCloudFrontProvider(&provider.CloudFrontConfig{
+		Client:                client,
+		PrimaryDistributionId: "EPRIMARY",
+		StagingDistributionId: "ESTAGING",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &progressived.Progressived{
+		Provider:  prov,
+		Metrics:   &fakeMetrics{},
+		Builder:   metrics.NewQueryBuikder("", map[string]interface{}{}),
+		Algorithm: algorithm.NewIncretion(5),
+		Formura:   formura.NewFormula("x < 1"),
+	}
+
+	// the step above the max staging weight promotes the staging distribution
+	for _, expected := range []float64{5, 10, 15, 20} {
+		v, err := p.Update()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != expected {
+			t.Fatalf("expected %f, but got %f", expected, v)
+		}
+	}
+	if client.promoted != "ESTAGING" {
+		t.Errorf("expected the staging distribution to be promoted, but got %s", client.promoted)
+	}
+	if v, err := p.CurrentPercentage(); err != nil || v != 100 {
+		t.Errorf("expected 100, but got %f, %v", v, err)
+	}
+	if _, err := p.Update(); err == nil {
+		t.Error("expected AlreadyCompletedError")
+	} else if _, ok := err.(progressived.AlreadyCompletedError); !ok {
+		t.Errorf("expected AlreadyCompletedError, but got %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ConsulProviderType = "consul"
+
+	// ConsulTotalWeight is the sum of the weights of the splits in units of 0.01%,
+	// which is the precision Consul accepts.
+	ConsulTotalWeight = 10000
+	// ConsulDefaultAddress is used when ConsulConfig.Address and CONSUL_HTTP_ADDR are not set.
+	ConsulDefaultAddress = "http://127.0.0.1:8500"
+
+	consulDefaultRetries = 5
+	consulDefaultTimeout = 30 * time.Second
+)
+
+type ConsulConfig struct {
+	Client *http.Client
+
+	// Address of the Consul HTTP API. Defaults to the CONSUL_HTTP_ADDR environment variable or ConsulDefaultAddress.
+	Address string
+	// Token defaults to the CONSUL_HTTP_TOKEN environment variable.
+	Token      string
+	Datacenter string
+	Namespace  string
+
+	// ServiceName is the name of the service-splitter config entry.
+	ServiceName       string
+	SourceSubset      string
+	DestinationSubset string
+
+	// Retries is the number of times the update is retried when the entry was changed by others. Defaults to 5.
+	Retries int
+}
+
+// ConsulProvider shifts the weight between two service subsets in a service-splitter config entry.
+type ConsulProvider struct {
+	client  *http.Client
+	address string
+	token   string
+	config  *ConsulConfig
+}
+
+// consulServiceSplitter is a service-splitter config entry. The fields that are
+// not used by the provider are kept in the raw entry, so that they are written back as they are.
+type consulServiceSplitter struct {
+	raw         map[string]interface{}
+	splits      []map[string]interface{}
+	modifyIndex uint64
+}
+
+func (p *ConsulProvider) TargetName() string {
+	return fmt.Sprintf("Consul/ServiceSplitter/%s", p.config.ServiceName)
+}
+
+func (p *ConsulProvider) MaxWeight() int64 {
+	return ConsulTotalWeight
+}
+
+func (p *ConsulProvider) TotalWeight() int64 {
+	return ConsulTotalWeight
+}
+
+func (p *ConsulProvider) url(path string, params url.Values) string {
+	if p.config.Datacenter != "" {
+		params.Set("dc", p.config.Datacenter)
+	}
+	if p.config.Namespace != "" {
+		params.Set("ns", p.config.Namespace)
+	}
+	u := p.address + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	return u
+}
+
+func (p *ConsulProvider) do(method string, u string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.token != "" {
+		req.Header.Set("X-Consul-Token", p.token)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("service-splitter `%s` is not found", p.config.ServiceName)
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status code %d: %s", res.StatusCode, strings.TrimSpace(string(b)))
+	}
+	return b, nil
+}
+
+func (p *ConsulProvider) getServiceSplitter() (*consulServiceSplitter, error) {
+	b, err := p.do(http.MethodGet, p.url("/v1/config/service-splitter/"+url.PathEscape(p.config.ServiceName), url.Values{}), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service-splitter `%s`: %w", p.config.ServiceName, err)
+	}
+
+	entry := &consulServiceSplitter{}
+	if err := json.Unmarshal(b, &entry.raw); err != nil {
+		return nil, fmt.Errorf("unmarshal service-splitter `%s` failed: %w", p.config.ServiceName, err)
+	}
+	if index, ok := entry.raw["ModifyIndex"].(float64); ok {
+		entry.modifyIndex = uint64(index)
+	}
+	splits, _ := entry.raw["Splits"].([]interface{})
+	for _, s := range splits {
+		if split, ok := s.(map[string]interface{}); ok {
+			entry.splits = append(entry.splits, split)
+		}
+	}
+	return entry, nil
+}
+
+// findSplits returns the splits of the source and destination subsets.
+func (p *ConsulProvider) findSplits(entry *consulServiceSplitter) (src map[string]interface{}, dest map[string]interface{}, err error) {
+	for _, split := range entry.splits {
+		if service, _ := split["Service"].(string); service != "" && service != p.config.ServiceName {
+			continue
+		}
+		switch subset, _ := split["ServiceSubset"].(string); subset {
+		case p.config.SourceSubset:
+			src = split
+		case p.config.DestinationSubset:
+			dest = split
+		}
+	}
+	if src == nil || dest == nil {
+		return nil, nil, fmt.Errorf("splits of the subsets `%s` and `%s` were not found in service-splitter `%s`", p.config.SourceSubset, p.config.DestinationSubset, p.config.ServiceName)
+	}
+	return src, dest, nil
+}
+
+// consulWeight returns the weight of the split in units of 0.01%.
+func consulWeight(split map[string]interface{}) int64 {
+	weight, _ := split["Weight"].(float64)
+	return int64(math.Round(weight * 100))
+}
+
+func (p *ConsulProvider) Get() (float64, error) {
+	entry, err := p.getServiceSplitter()
+	if err != nil {
+		return -1, err
+	}
+	src, dest, err := p.findSplits(entry)
+	if err != nil {
+		return -1, err
+	}
+
+	return WeightPercentage(consulWeight(src), consulWeight(dest)), nil
+}
+
+func (p *ConsulProvider) Update(percentage float64) error {
+	sourceWeight, destinationWeight := DistributeWeight(percentage, ConsulTotalWeight)
+
+	for i := 0; ; i++ {
+		entry, err := p.getServiceSplitter()
+		if err != nil {
+			return err
+		}
+		src, dest, err := p.findSplits(entry)
+		if err != nil {
+			return err
+		}
+		src["Weight"] = float64(sourceWeight) / 100
+		dest["Weight"] = float64(destinationWeight) / 100
+
+		var total int64
+		for _, split := range entry.splits {
+			total += consulWeight(split)
+		}
+		if total != ConsulTotalWeight {
+			return fmt.Errorf("weights of the splits in service-splitter `%s` must sum to 100, but got %.2f. other splits must have no weight", p.config.ServiceName, float64(total)/100)
+		}
+
+		body, err := json.Marshal(entry.raw)
+		if err != nil {
+			return err
+		}
+		params := url.Values{}
+		params.Set("cas", strconv.FormatUint(entry.modifyIndex, 10))
+		b, err := p.do(http.MethodPut, p.url("/v1/config", params), body)
+		if err != nil {
+			return fmt.Errorf("failed to update service-splitter `%s`: %w", p.config.ServiceName, err)
+		}
+		// the entry is read again if it was changed after it was read
+		if strings.TrimSpace(string(b)) == "true" {
+			return nil
+		}
+		if i >= p.config.Retries {
+			return fmt.Errorf("failed to update service-splitter `%s`: it was modified concurrently", p.config.ServiceName)
+		}
+	}
+}
+
+func NewConsulProvider(config *ConsulConfig) (*ConsulProvider, error) {
+	if config.ServiceName == "" {
+		return nil, errors.New("ConsulConfig.ServiceName is missing")
+	}
+	if config.SourceSubset == config.DestinationSubset {
+		return nil, errors.New("ConsulConfig.SourceSubset and ConsulConfig.DestinationSubset must be different")
+	}
+	if config.Retries < 0 {
+		return nil, errors.New("ConsulConfig.Retries must be greater than or equal to 0")
+	}
+	if config.Retries == 0 {
+		config.Retries = consulDefaultRetries
+	}
+
+	address := config.Address
+	if address == "" {
+		address = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if address == "" {
+		address = ConsulDefaultAddress
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	token := config.Token
+	if token == "" {
+		token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+
+	client := config.Client
+	if client == nil {
+		client = &http.Client{Timeout: consulDefaultTimeout}
+	}
+
+	return &ConsulProvider{
+		client:  client,
+		address: strings.TrimSuffix(address, "/"),
+		token:   token,
+		config:  config,
+	}, nil
+}
@@ -0,0 +1,118 @@
+package provider_test
+
+import (
+	"encoding/json"
+	"github.com/k-kinzal/progressived/pkg/provider"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// consulStandIn serves a service-splitter config entry with check-and-set updates.
+type consulStandIn struct {
+	mu    sync.Mutex
+	entry map[string]interface{}
+	index uint64
+	// conflicts is the number of updates that are rejected as if the entry was changed by others
+	conflicts int
+	puts      int
+}
+
+func (s *consulStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/config/service-splitter/web":
+		s.entry["ModifyIndex"] = s.index
+		json.NewEncoder(w).Encode(s.entry)
+	case r.Method == http.MethodPut && r.URL.Path == "/v1/config":
+		s.puts++
+		cas, _ := strconv.ParseUint(r.URL.Query().Get("cas"), 10, 64)
+		if s.conflicts > 0 {
+			s.conflicts--
+			s.index++
+		}
+		if cas != s.index {
+			w.Write([]byte("false"))
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		var entry map[string]interface{}
+		if err := json.Unmarshal(b, &entry); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.entry = entry
+		s.index++
+		w.Write([]byte("true"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestConsulProvider_Update(t *testing.T) {
+	standIn := &consulStandIn{
+		entry: map[string]interface{}{
+			"Kind": "service-splitter",
+			"Name": "web",
+			"Meta": map[string]interface{}{"owner": "team"},
+			"Splits": []interface{}{
+				map[string]interface{}{"Weight": 100, "ServiceSubset": "v1"},
+				map[string]interface{}{"Weight": 0, "ServiceSubset": "v2"},
+			},
+		},
+		index:     10,
+		conflicts: 1,
+	}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	p, err := provider.NewConsulProvider(&provider.ConsulConfig{
+		Address:           server.URL,
+		ServiceName:       "web",
+		SourceSubset:      "v1",
+		DestinationSubset: "v2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(12.5); err != nil {
+		t.Fatal(err)
+	}
+	if standIn.puts != 2 {
+		t.Errorf("expected the update to be retried once, but it was sent %d times", standIn.puts)
+	}
+	if meta, ok := standIn.entry["Meta"].(map[string]interface{}); !ok || meta["owner"] != "team" {
+		t.Errorf("expected the other fields to be preserved, but got %v", standIn.entry)
+	}
+
+	v, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 12.5 {
+		t.Errorf("expected 12.5, but got %f", v)
+	}
+}
+
+func TestConsulProvider_Get_NotFound(t *testing.T) {
+	server := httptest.NewServer(&consulStandIn{})
+	defer server.Close()
+
+	p, err := provider.NewConsulProvider(&provider.ConsulConfig{
+		Address:           server.URL,
+		ServiceName:       "api",
+		SourceSubset:      "v1",
+		DestinationSubset: "v2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Get(); err == nil {
+		t.Error("expected an error because the service-splitter is missing")
+	}
+}
@@ -0,0 +1,211 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+	"strings"
+)
+
+const (
+	GatewayAPIProviderType = "gateway-api"
+
+	// GatewayAPIMaxWeight is the largest weight of a backendRef.
+	GatewayAPIMaxWeight = 1000000
+	// GatewayAPIDefaultTotalWeight is used when GatewayAPIConfig.TotalWeight is not set.
+	GatewayAPIDefaultTotalWeight = 100
+	// GatewayAPIDefaultVersion is the version of gateway.networking.k8s.io used when GatewayAPIConfig.Version is not set.
+	GatewayAPIDefaultVersion = "v1"
+
+	gatewayAPIGroup = "gateway.networking.k8s.io"
+)
+
+type GatewayAPIConfig struct {
+	Client     dynamic.Interface
+	Kubernetes *KubernetesConfig
+
+	// Version of the Gateway API, e.g. `v1` or `v1beta1`.
+	Version   string
+	Namespace string
+	// RouteName is the name of the HTTPRoute.
+	RouteName string
+	// RuleName is the name of the rule. When it is missing, the first rule that
+	// has both the source and destination backendRefs is used.
+	RuleName string
+	// SourceService and DestinationService are the names of the services in the backendRefs.
+	SourceService      string
+	DestinationService string
+
+	// TotalWeight is the sum of the source and destination weights.
+	TotalWeight int64
+}
+
+// GatewayAPIProvider adjusts the weights of two backendRefs in a rule of a Gateway API HTTPRoute.
+type GatewayAPIProvider struct {
+	client dynamic.ResourceInterface
+	config *GatewayAPIConfig
+}
+
+func (p *GatewayAPIProvider) TargetName() string {
+	return fmt.Sprintf("Kubernetes/HTTPRoute/%s/%s", p.config.Namespace, p.config.RouteName)
+}
+
+func (p *GatewayAPIProvider) MaxWeight() int64 {
+	return GatewayAPIMaxWeight
+}
+
+func (p *GatewayAPIProvider) TotalWeight() int64 {
+	return p.config.TotalWeight
+}
+
+// findBackendRefs returns a copy of the rules of the route and the source and destination backendRefs in it.
+func (p *GatewayAPIProvider) findBackendRefs(route *unstructured.Unstructured) (rules []interface{}, src map[string]interface{}, dest map[string]interface{}, err error) {
+	rules, found, err := unstructured.NestedSlice(route.Object, "spec", "rules")
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid rules in HTTPRoute `%s`: %w", p.config.RouteName, err)
+	}
+	if !found {
+		return nil, nil, nil, fmt.Errorf("HTTPRoute `%s` has no rules", p.config.RouteName)
+	}
+	for _, r := range rules {
+		rule, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if p.config.RuleName != "" {
+			if name, _, _ := unstructured.NestedString(rule, "name"); name != p.config.RuleName {
+				continue
+			}
+		}
+		// not NestedSlice, which returns a copy, so that the weights can be set to the backendRefs in the rules
+		refs, _ := rule["backendRefs"].([]interface{})
+		var s, d map[string]interface{}
+		for _, ref := range refs {
+			backendRef, ok := ref.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			kind, _, _ := unstructured.NestedString(backendRef, "kind")
+			if kind != "" && kind != "Service" {
+				continue
+			}
+			switch name, _, _ := unstructured.NestedString(backendRef, "name"); name {
+			case p.config.SourceService:
+				s = backendRef
+			case p.config.DestinationService:
+				d = backendRef
+			}
+		}
+		if s != nil && d != nil {
+			return rules, s, d, nil
+		}
+		if p.config.RuleName != "" {
+			break
+		}
+	}
+	if p.config.RuleName != "" {
+		return nil, nil, nil, fmt.Errorf("backendRefs of `%s` and `%s` were not found in rule `%s` of HTTPRoute `%s`", p.config.SourceService, p.config.DestinationService, p.config.RuleName, p.config.RouteName)
+	}
+	return nil, nil, nil, fmt.Errorf("backendRefs of `%s` and `%s` were not found in HTTPRoute `%s`", p.config.SourceService, p.config.DestinationService, p.config.RouteName)
+}
+
+// backendRefWeight returns the weight of the backendRef. It is 1 when the weight is not specified.
+func backendRefWeight(backendRef map[string]interface{}) int64 {
+	weight, found, err := unstructured.NestedInt64(backendRef, "weight")
+	if err != nil || !found {
+		return 1
+	}
+	return weight
+}
+
+func (p *GatewayAPIProvider) Get() (float64, error) {
+	route, err := p.client.Get(context.Background(), p.config.RouteName, metav1.GetOptions{})
+	if err != nil {
+		return -1, fmt.Errorf("failed to get HTTPRoute `%s`: %w", p.config.RouteName, err)
+	}
+	_, src, dest, err := p.findBackendRefs(route)
+	if err != nil {
+		return -1, err
+	}
+
+	return WeightPercentage(backendRefWeight(src), backendRefWeight(dest)), nil
+}
+
+func (p *GatewayAPIProvider) Update(percentage float64) error {
+	sourceWeight, destinationWeight := DistributeWeight(percentage, p.config.TotalWeight)
+
+	// the route is read again on a conflict, so that changes by others are not overwritten
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		route, err := p.client.Get(context.Background(), p.config.RouteName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get HTTPRoute `%s`: %w", p.config.RouteName, err)
+		}
+		rules, src, dest, err := p.findBackendRefs(route)
+		if err != nil {
+			return err
+		}
+		// src and dest are in the copy of the rules, so the rules are set back to the route
+		src["weight"] = sourceWeight
+		dest["weight"] = destinationWeight
+		if err := unstructured.SetNestedSlice(route.Object, rules, "spec", "rules"); err != nil {
+			return err
+		}
+
+		if _, err := p.client.Update(context.Background(), route, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func NewGatewayAPIProvider(config *GatewayAPIConfig) (*GatewayAPIProvider, error) {
+	if config.Namespace == "" {
+		return nil, errors.New("GatewayAPIConfig.Namespace is missing")
+	}
+	if config.RouteName == "" {
+		return nil, errors.New("GatewayAPIConfig.RouteName is missing")
+	}
+	if config.SourceService == "" {
+		return nil, errors.New("GatewayAPIConfig.SourceService is missing")
+	}
+	if config.DestinationService == "" {
+		return nil, errors.New("GatewayAPIConfig.DestinationService is missing")
+	}
+	if config.SourceService == config.DestinationService {
+		return nil, errors.New("GatewayAPIConfig.SourceService and GatewayAPIConfig.DestinationService must be different")
+	}
+	if config.Version == "" {
+		config.Version = GatewayAPIDefaultVersion
+	}
+	config.Version = strings.TrimPrefix(config.Version, gatewayAPIGroup+"/")
+	if config.TotalWeight == 0 {
+		config.TotalWeight = GatewayAPIDefaultTotalWeight
+	}
+	if err := validateTotalWeight("GatewayAPIConfig.TotalWeight", config.TotalWeight, GatewayAPIMaxWeight); err != nil {
+		return nil, err
+	}
+
+	client := config.Client
+	if client == nil {
+		c, err := newKubernetesRESTConfig(config.Kubernetes)
+		if err != nil {
+			return nil, err
+		}
+		d, err := dynamic.NewForConfig(c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+		}
+		client = d
+	}
+	resource := schema.GroupVersionResource{Group: gatewayAPIGroup, Version: config.Version, Resource: "httproutes"}
+
+	return &GatewayAPIProvider{
+		client: client.Resource(resource).Namespace(config.Namespace),
+		config: config,
+	}, nil
+}
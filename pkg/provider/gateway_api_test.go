@@ -0,0 +1,108 @@
+package provider_test
+
+import (
+	"context"
+	"github.com/k-kinzal/progressived/pkg/provider"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+)
+
+var httpRouteResource = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}
+
+func newHTTPRoute() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind":       "HTTPRoute",
+		"metadata": map[string]interface{}{
+			"name":      "app",
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"rules": []interface{}{
+				map[string]interface{}{
+					"name": "api",
+					"backendRefs": []interface{}{
+						map[string]interface{}{"name": "app-blue", "port": int64(80), "weight": int64(100)},
+						map[string]interface{}{"name": "app-green", "port": int64(80), "weight": int64(0)},
+					},
+				},
+			},
+		},
+	}}
+}
+
+func newFakeDynamicClient(objects ...runtime.Object) *fake.FakeDynamicClient {
+	return fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		httpRouteResource: "HTTPRouteList",
+	}, objects...)
+}
+
+func TestGatewayAPIProvider_Update(t *testing.T) {
+	client := newFakeDynamicClient(newHTTPRoute())
+	// the first update conflicts with another writer
+	conflicted := false
+	client.PrependReactor("update", "httproutes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicted {
+			return false, nil, nil
+		}
+		conflicted = true
+		return true, nil, apierrors.NewConflict(httpRouteResource.GroupResource(), "app", nil)
+	})
+
+	p, err := provider.NewGatewayAPIProvider(&provider.GatewayAPIConfig{
+		Client:             client,
+		Namespace:          "default",
+		RouteName:          "app",
+		RuleName:           "api",
+		SourceService:      "app-blue",
+		DestinationService: "app-green",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(25); err != nil {
+		t.Fatal(err)
+	}
+	if !conflicted {
+		t.Error("expected the update to be retried on the conflict")
+	}
+
+	v, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 25 {
+		t.Errorf("expected 25, but got %f", v)
+	}
+	route, err := client.Resource(httpRouteResource).Namespace("default").Get(context.Background(), "app", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	refs := rules[0].(map[string]interface{})["backendRefs"].([]interface{})
+	if w := refs[0].(map[string]interface{})["weight"]; w != int64(75) {
+		t.Errorf("expected the weight of the source to be 75, but got %v", w)
+	}
+}
+
+func TestGatewayAPIProvider_Get_NotFound(t *testing.T) {
+	p, err := provider.NewGatewayAPIProvider(&provider.GatewayAPIConfig{
+		Client:             newFakeDynamicClient(newHTTPRoute()),
+		Namespace:          "default",
+		RouteName:          "app",
+		SourceService:      "app-blue",
+		DestinationService: "app-red",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Get(); err == nil {
+		t.Error("expected an error because the destination backendRef is missing")
+	}
+}
@@ -0,0 +1,258 @@
+package provider
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HAProxyProviderType = "haproxy"
+
+	// HAProxyMaxWeight is the largest weight of a server.
+	HAProxyMaxWeight = 256
+	// HAProxyDefaultTotalWeight is used when HAProxyConfig.TotalWeight is not set.
+	HAProxyDefaultTotalWeight = 256
+
+	haproxyDefaultTimeout = 10 * time.Second
+)
+
+var (
+	haproxyWeightRegexp  = regexp.MustCompile(`^(\d+)`)
+	haproxyServerRegexp  = regexp.MustCompile(`^\s*server\s+(\S+)\s`)
+	haproxyOptionRegexp  = regexp.MustCompile(`(\s)weight\s+\d+`)
+	haproxySectionRegexp = regexp.MustCompile(`^\s*(global|defaults|frontend|backend|listen|peers|resolvers|userlist|program|http-errors|ring|mailers|cache)(?:\s+(\S+))?`)
+)
+
+type HAProxyConfig struct {
+	// Address of the runtime API. A path or `unix://` for a unix socket, `host:port` or `tcp://` for TCP.
+	Address string
+	Backend string
+	// SourceServers and DestinationServers are the servers of the old and new groups in the backend.
+	SourceServers      []string
+	DestinationServers []string
+	// ConfigFile is the HAProxy config file where the weights are persisted, so that they survive reloads.
+	ConfigFile string
+
+	// TotalWeight is the sum of the weights of all source and destination servers.
+	TotalWeight int64
+	Timeout     time.Duration
+}
+
+// HAProxyProvider sets the weights of the servers in a backend through the HAProxy runtime API.
+type HAProxyProvider struct {
+	network string
+	address string
+	config  *HAProxyConfig
+}
+
+func (p *HAProxyProvider) TargetName() string {
+	return fmt.Sprintf("HAProxy/%s", p.config.Backend)
+}
+
+func (p *HAProxyProvider) MaxWeight() int64 {
+	return HAProxyMaxWeight
+}
+
+func (p *HAProxyProvider) TotalWeight() int64 {
+	return p.config.TotalWeight
+}
+
+// command sends a command to the runtime API and returns the response.
+func (p *HAProxyProvider) command(cmd string) (string, error) {
+	conn, err := net.DialTimeout(p.network, p.address, p.config.Timeout)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to HAProxy runtime API: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(p.config.Timeout))
+
+	if _, err := fmt.Fprintf(conn, "%s\n", cmd); err != nil {
+		return "", fmt.Errorf("failed to send `%s` to HAProxy runtime API: %w", cmd, err)
+	}
+	// the runtime API closes the connection after the response in the non-interactive mode
+	b, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the response of `%s` from HAProxy runtime API: %w", cmd, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func (p *HAProxyProvider) getWeight(server string) (int64, error) {
+	res, err := p.command(fmt.Sprintf("get weight %s/%s", p.config.Backend, server))
+	if err != nil {
+		return 0, err
+	}
+	// the response is like `12 (initial 1)`
+	match := haproxyWeightRegexp.FindStringSubmatch(res)
+	if match == nil {
+		return 0, fmt.Errorf("failed to get weight of `%s/%s`: %s", p.config.Backend, server, res)
+	}
+	return strconv.ParseInt(match[1], 10, 64)
+}
+
+func (p *HAProxyProvider) setWeight(server string, weight int64) error {
+	res, err := p.command(fmt.Sprintf("set weight %s/%s %d", p.config.Backend, server, weight))
+	if err != nil {
+		return err
+	}
+	// nothing is returned on success
+	if res != "" {
+		return fmt.Errorf("failed to set weight of `%s/%s`: %s", p.config.Backend, server, res)
+	}
+	return nil
+}
+
+func (p *HAProxyProvider) Get() (float64, error) {
+	var src, dest int64
+	for _, server := range p.config.SourceServers {
+		w, err := p.getWeight(server)
+		if err != nil {
+			return -1, err
+		}
+		src += w
+	}
+	for _, server := range p.config.DestinationServers {
+		w, err := p.getWeight(server)
+		if err != nil {
+			return -1, err
+		}
+		dest += w
+	}
+
+	return WeightPercentage(src, dest), nil
+}
+
+// serverWeights returns the weight of each server. The weight of a group is
+// divided among its servers, so that the share does not depend on the size of the groups.
+func (p *HAProxyProvider) serverWeights(percentage float64) map[string]int64 {
+	sourceWeight, destinationWeight := DistributeWeight(percentage, p.config.TotalWeight)
+	weights := make(map[string]int64)
+	for i, w := range divideGroupWeights(sourceWeight, len(p.config.SourceServers)) {
+		weights[p.config.SourceServers[i]] = w
+	}
+	for i, w := range divideGroupWeights(destinationWeight, len(p.config.DestinationServers)) {
+		weights[p.config.DestinationServers[i]] = w
+	}
+	return weights
+}
+
+func (p *HAProxyProvider) Update(percentage float64) error {
+	weights := p.serverWeights(percentage)
+
+	servers := append(append([]string{}, p.config.SourceServers...), p.config.DestinationServers...)
+	for _, server := range servers {
+		if err := p.setWeight(server, weights[server]); err != nil {
+			return err
+		}
+	}
+
+	if p.config.ConfigFile != "" {
+		if err := persistHAProxyWeights(p.config.ConfigFile, p.config.Backend, weights); err != nil {
+			return fmt.Errorf("failed to persist weights to `%s`: %w", p.config.ConfigFile, err)
+		}
+	}
+	return nil
+}
+
+// setHAProxyWeightOption replaces the weight option of the server line, or adds it before the comment.
+func setHAProxyWeightOption(line string, weight int64) string {
+	if haproxyOptionRegexp.MatchString(line) {
+		return haproxyOptionRegexp.ReplaceAllString(line, fmt.Sprintf("${1}weight %d", weight))
+	}
+	comment := ""
+	if i := strings.Index(line, "#"); i >= 0 {
+		line, comment = line[:i], " "+line[i:]
+	}
+	return fmt.Sprintf("%s weight %d%s", strings.TrimRight(line, " \t"), weight, comment)
+}
+
+// persistHAProxyWeights rewrites the weights of the servers in the backend section of the config file.
+func persistHAProxyWeights(path string, backend string, weights map[string]int64) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	inBackend := false
+	found := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(string(b)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := haproxySectionRegexp.FindStringSubmatch(line); m != nil {
+			inBackend = (m[1] == "backend" || m[1] == "listen") && m[2] == backend
+		} else if inBackend {
+			if m := haproxyServerRegexp.FindStringSubmatch(line); m != nil {
+				if weight, ok := weights[m[1]]; ok {
+					line = setHAProxyWeightOption(line, weight)
+					found[m[1]] = true
+				}
+			}
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for server := range weights {
+		if !found[server] {
+			return fmt.Errorf("server `%s` is not found in backend `%s`", server, backend)
+		}
+	}
+
+	return writeFileAtomically(path, []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+func NewHAProxyProvider(config *HAProxyConfig) (*HAProxyProvider, error) {
+	if config.Address == "" {
+		return nil, errors.New("HAProxyConfig.Address is missing")
+	}
+	if config.Backend == "" {
+		return nil, errors.New("HAProxyConfig.Backend is missing")
+	}
+	if len(config.SourceServers) == 0 {
+		return nil, errors.New("HAProxyConfig.SourceServers is missing")
+	}
+	if len(config.DestinationServers) == 0 {
+		return nil, errors.New("HAProxyConfig.DestinationServers is missing")
+	}
+	for _, src := range config.SourceServers {
+		for _, dest := range config.DestinationServers {
+			if src == dest {
+				return nil, fmt.Errorf("server `%s` must not be in both HAProxyConfig.SourceServers and HAProxyConfig.DestinationServers", src)
+			}
+		}
+	}
+	if config.TotalWeight == 0 {
+		config.TotalWeight = HAProxyDefaultTotalWeight
+	}
+	if err := validateTotalWeight("HAProxyConfig.TotalWeight", config.TotalWeight, HAProxyMaxWeight); err != nil {
+		return nil, err
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = haproxyDefaultTimeout
+	}
+
+	network, address := "tcp", config.Address
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		network, address = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "tcp://"):
+		address = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "/"), strings.HasPrefix(address, "."):
+		network = "unix"
+	}
+
+	return &HAProxyProvider{
+		network: network,
+		address: address,
+		config:  config,
+	}, nil
+}
@@ -0,0 +1,134 @@
+package provider_test
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/k-kinzal/progressived/pkg/provider"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// startHAProxyStandIn serves `get weight` and `set weight` of the runtime API on a unix socket.
+func startHAProxyStandIn(t *testing.T, dir string, weights map[string]int64) (string, func()) {
+	path := filepath.Join(dir, "haproxy.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			fields := strings.Fields(line)
+			mu.Lock()
+			switch {
+			case len(fields) == 3 && fields[0] == "get" && fields[1] == "weight":
+				if w, ok := weights[fields[2]]; ok {
+					fmt.Fprintf(conn, "%d (initial 1)\n", w)
+				} else {
+					fmt.Fprint(conn, "No such server.\n")
+				}
+			case len(fields) == 4 && fields[0] == "set" && fields[1] == "weight":
+				if _, ok := weights[fields[2]]; ok {
+					w, _ := strconv.ParseInt(fields[3], 10, 64)
+					weights[fields[2]] = w
+				} else {
+					fmt.Fprint(conn, "No such server.\n")
+				}
+			default:
+				fmt.Fprint(conn, "Unknown command.\n")
+			}
+			mu.Unlock()
+			conn.Close()
+		}
+	}()
+	return path, func() { l.Close() }
+}
+
+func TestHAProxyProvider_Update(t *testing.T) {
+	dir, err := ioutil.TempDir("", "haproxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	weights := map[string]int64{"app/blue1": 128, "app/blue2": 128, "app/green1": 0}
+	socket, stop := startHAProxyStandIn(t, dir, weights)
+	defer stop()
+
+	configFile := filepath.Join(dir, "haproxy.cfg")
+	config := `backend web
+    server blue1 10.0.0.1:80 check
+backend app
+    balance roundrobin
+    server blue1 10.0.0.1:80 check weight 128 # old
+    server blue2 10.0.0.2:80 weight 128 check
+    server green1 10.0.0.3:80 check weight 0
+`
+	if err := ioutil.WriteFile(configFile, []byte(config), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := provider.NewHAProxyProvider(&provider.HAProxyConfig{
+		Address:            socket,
+		Backend:            "app",
+		SourceServers:      []string{"blue1", "blue2"},
+		DestinationServers: []string{"green1"},
+		ConfigFile:         configFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := p.Get(); err != nil || v != 0 {
+		t.Errorf("expected 0, but got %f, %v", v, err)
+	}
+	if err := p.Update(25); err != nil {
+		t.Fatal(err)
+	}
+	if weights["app/blue1"] != 96 || weights["app/blue2"] != 96 || weights["app/green1"] != 64 {
+		t.Errorf("unexpected weights: %v", weights)
+	}
+	if v, err := p.Get(); err != nil || v != 25 {
+		t.Errorf("expected 25, but got %f, %v", v, err)
+	}
+
+	// the remainder of the odd source weight goes to the first server
+	if err := p.Update(30); err != nil {
+		t.Fatal(err)
+	}
+	if weights["app/blue1"] != 90 || weights["app/blue2"] != 89 || weights["app/green1"] != 77 {
+		t.Errorf("unexpected weights: %v", weights)
+	}
+	if v, err := p.Get(); err != nil || v != provider.QuantizePercentage(30, provider.HAProxyDefaultTotalWeight) {
+		t.Errorf("expected %f, but got %f, %v", provider.QuantizePercentage(30, provider.HAProxyDefaultTotalWeight), v, err)
+	}
+	if err := p.Update(25); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `backend web
+    server blue1 10.0.0.1:80 check
+backend app
+    balance roundrobin
+    server blue1 10.0.0.1:80 check weight 96 # old
+    server blue2 10.0.0.2:80 weight 96 check
+    server green1 10.0.0.3:80 check weight 64
+`
+	if string(b) != expected {
+		t.Errorf("unexpected config file:\n%s", string(b))
+	}
+}
@@ -0,0 +1,221 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+	"strings"
+)
+
+const (
+	IstioProviderType = "istio"
+
+	// IstioTotalWeight is the sum of the weights of the destinations in a route, which Istio requires to be 100.
+	IstioTotalWeight = 100
+	// IstioDefaultVersion is the version of networking.istio.io used when IstioConfig.Version is not set.
+	IstioDefaultVersion = "v1beta1"
+
+	istioGroup = "networking.istio.io"
+)
+
+// IstioDestination is a destination of a route in a VirtualService.
+type IstioDestination struct {
+	Host   string
+	Subset string
+}
+
+func (d IstioDestination) String() string {
+	if d.Subset == "" {
+		return d.Host
+	}
+	return fmt.Sprintf("%s/%s", d.Host, d.Subset)
+}
+
+type IstioConfig struct {
+	Client     dynamic.Interface
+	Kubernetes *KubernetesConfig
+
+	// Version of networking.istio.io, e.g. `v1beta1` or `v1`.
+	Version   string
+	Namespace string
+	// VirtualServiceName is the name of the VirtualService.
+	VirtualServiceName string
+	// RouteName is the name of the HTTP route. When it is missing, the first HTTP
+	// route that has both the source and destination is used.
+	RouteName   string
+	Source      IstioDestination
+	Destination IstioDestination
+}
+
+// IstioProvider adjusts the weights of two destinations in an HTTP route of an Istio VirtualService.
+type IstioProvider struct {
+	client dynamic.ResourceInterface
+	config *IstioConfig
+}
+
+func (p *IstioProvider) TargetName() string {
+	return fmt.Sprintf("Kubernetes/VirtualService/%s/%s", p.config.Namespace, p.config.VirtualServiceName)
+}
+
+func (p *IstioProvider) MaxWeight() int64 {
+	return IstioTotalWeight
+}
+
+func (p *IstioProvider) TotalWeight() int64 {
+	return IstioTotalWeight
+}
+
+func istioDestinationOf(route map[string]interface{}) IstioDestination {
+	host, _, _ := unstructured.NestedString(route, "destination", "host")
+	subset, _, _ := unstructured.NestedString(route, "destination", "subset")
+	return IstioDestination{Host: host, Subset: subset}
+}
+
+// istioWeight returns the weight of the route destination. It is 0 when the weight is not specified.
+func istioWeight(route map[string]interface{}) int64 {
+	weight, _, _ := unstructured.NestedInt64(route, "weight")
+	return weight
+}
+
+// findRoutes returns a copy of the HTTP routes of the VirtualService, the route
+// destinations of the source and destination in it, and all destinations of the route.
+func (p *IstioProvider) findRoutes(vs *unstructured.Unstructured) (https []interface{}, src map[string]interface{}, dest map[string]interface{}, routes []interface{}, err error) {
+	https, found, err := unstructured.NestedSlice(vs.Object, "spec", "http")
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("invalid http routes in VirtualService `%s`: %w", p.config.VirtualServiceName, err)
+	}
+	if !found {
+		return nil, nil, nil, nil, fmt.Errorf("VirtualService `%s` has no http routes", p.config.VirtualServiceName)
+	}
+	for _, h := range https {
+		http, ok := h.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if p.config.RouteName != "" {
+			if name, _, _ := unstructured.NestedString(http, "name"); name != p.config.RouteName {
+				continue
+			}
+		}
+		// not NestedSlice, which returns a copy, so that the weights can be set to the destinations in the routes
+		rs, _ := http["route"].([]interface{})
+		var s, d map[string]interface{}
+		for _, r := range rs {
+			route, ok := r.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch istioDestinationOf(route) {
+			case p.config.Source:
+				s = route
+			case p.config.Destination:
+				d = route
+			}
+		}
+		if s != nil && d != nil {
+			return https, s, d, rs, nil
+		}
+		if p.config.RouteName != "" {
+			break
+		}
+	}
+	if p.config.RouteName != "" {
+		return nil, nil, nil, nil, fmt.Errorf("destinations `%s` and `%s` were not found in http route `%s` of VirtualService `%s`", p.config.Source, p.config.Destination, p.config.RouteName, p.config.VirtualServiceName)
+	}
+	return nil, nil, nil, nil, fmt.Errorf("destinations `%s` and `%s` were not found in VirtualService `%s`", p.config.Source, p.config.Destination, p.config.VirtualServiceName)
+}
+
+func (p *IstioProvider) Get() (float64, error) {
+	vs, err := p.client.Get(context.Background(), p.config.VirtualServiceName, metav1.GetOptions{})
+	if err != nil {
+		return -1, fmt.Errorf("failed to get VirtualService `%s`: %w", p.config.VirtualServiceName, err)
+	}
+	_, src, dest, _, err := p.findRoutes(vs)
+	if err != nil {
+		return -1, err
+	}
+
+	return WeightPercentage(istioWeight(src), istioWeight(dest)), nil
+}
+
+func (p *IstioProvider) Update(percentage float64) error {
+	sourceWeight, destinationWeight := DistributeWeight(percentage, IstioTotalWeight)
+
+	// the VirtualService is read again on a conflict, so that changes by others are not overwritten
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		vs, err := p.client.Get(context.Background(), p.config.VirtualServiceName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get VirtualService `%s`: %w", p.config.VirtualServiceName, err)
+		}
+		https, src, dest, routes, err := p.findRoutes(vs)
+		if err != nil {
+			return err
+		}
+		src["weight"] = sourceWeight
+		dest["weight"] = destinationWeight
+
+		var total int64
+		for _, r := range routes {
+			if route, ok := r.(map[string]interface{}); ok {
+				total += istioWeight(route)
+			}
+		}
+		if total != IstioTotalWeight {
+			return fmt.Errorf("weights of the destinations in VirtualService `%s` must sum to %d, but got %d. other destinations in the route must have no weight", p.config.VirtualServiceName, IstioTotalWeight, total)
+		}
+		if err := unstructured.SetNestedSlice(vs.Object, https, "spec", "http"); err != nil {
+			return err
+		}
+
+		if _, err := p.client.Update(context.Background(), vs, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func NewIstioProvider(config *IstioConfig) (*IstioProvider, error) {
+	if config.Namespace == "" {
+		return nil, errors.New("IstioConfig.Namespace is missing")
+	}
+	if config.VirtualServiceName == "" {
+		return nil, errors.New("IstioConfig.VirtualServiceName is missing")
+	}
+	if config.Source.Host == "" {
+		return nil, errors.New("IstioConfig.Source.Host is missing")
+	}
+	if config.Destination.Host == "" {
+		return nil, errors.New("IstioConfig.Destination.Host is missing")
+	}
+	if config.Source == config.Destination {
+		return nil, errors.New("IstioConfig.Source and IstioConfig.Destination must be different")
+	}
+	if config.Version == "" {
+		config.Version = IstioDefaultVersion
+	}
+	config.Version = strings.TrimPrefix(config.Version, istioGroup+"/")
+
+	client := config.Client
+	if client == nil {
+		c, err := newKubernetesRESTConfig(config.Kubernetes)
+		if err != nil {
+			return nil, err
+		}
+		d, err := dynamic.NewForConfig(c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+		}
+		client = d
+	}
+	resource := schema.GroupVersionResource{Group: istioGroup, Version: config.Version, Resource: "virtualservices"}
+
+	return &IstioProvider{
+		client: client.Resource(resource).Namespace(config.Namespace),
+		config: config,
+	}, nil
+}
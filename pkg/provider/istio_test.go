@@ -0,0 +1,90 @@
+package provider_test
+
+import (
+	"context"
+	"github.com/k-kinzal/progressived/pkg/provider"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	"testing"
+)
+
+var virtualServiceResource = schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1beta1", Resource: "virtualservices"}
+
+func newVirtualService(routes ...interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.istio.io/v1beta1",
+		"kind":       "VirtualService",
+		"metadata": map[string]interface{}{
+			"name":      "reviews",
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"hosts": []interface{}{"reviews"},
+			"http": []interface{}{
+				map[string]interface{}{
+					"name":    "primary",
+					"timeout": "10s",
+					"route":   routes,
+				},
+			},
+		},
+	}}
+}
+
+func newIstioProvider(t *testing.T, vs *unstructured.Unstructured) (*provider.IstioProvider, *fake.FakeDynamicClient) {
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		virtualServiceResource: "VirtualServiceList",
+	}, vs)
+	p, err := provider.NewIstioProvider(&provider.IstioConfig{
+		Client:             client,
+		Namespace:          "default",
+		VirtualServiceName: "reviews",
+		RouteName:          "primary",
+		Source:             provider.IstioDestination{Host: "reviews", Subset: "v1"},
+		Destination:        provider.IstioDestination{Host: "reviews", Subset: "v2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, client
+}
+
+func TestIstioProvider_Update(t *testing.T) {
+	p, client := newIstioProvider(t, newVirtualService(
+		map[string]interface{}{"destination": map[string]interface{}{"host": "reviews", "subset": "v1"}, "weight": int64(100)},
+		map[string]interface{}{"destination": map[string]interface{}{"host": "reviews", "subset": "v2"}},
+	))
+	if err := p.Update(30); err != nil {
+		t.Fatal(err)
+	}
+	v, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 30 {
+		t.Errorf("expected 30, but got %f", v)
+	}
+
+	vs, err := client.Resource(virtualServiceResource).Namespace("default").Get(context.Background(), "reviews", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	https, _, _ := unstructured.NestedSlice(vs.Object, "spec", "http")
+	if timeout := https[0].(map[string]interface{})["timeout"]; timeout != "10s" {
+		t.Errorf("expected the other fields to be preserved, but the timeout is %v", timeout)
+	}
+}
+
+func TestIstioProvider_Update_InvalidTotal(t *testing.T) {
+	p, _ := newIstioProvider(t, newVirtualService(
+		map[string]interface{}{"destination": map[string]interface{}{"host": "reviews", "subset": "v1"}, "weight": int64(80)},
+		map[string]interface{}{"destination": map[string]interface{}{"host": "reviews", "subset": "v2"}, "weight": int64(10)},
+		map[string]interface{}{"destination": map[string]interface{}{"host": "reviews", "subset": "v3"}, "weight": int64(10)},
+	))
+	if err := p.Update(30); err == nil {
+		t.Error("expected an error because the weights do not sum to 100")
+	}
+}
@@ -0,0 +1,37 @@
+package provider
+
+import (
+	"fmt"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// KubernetesConfig is how to connect to the Kubernetes API server.
+// The in-cluster configuration is used when Kubeconfig is missing and the
+// process runs in a pod, otherwise the default kubeconfig loading rules apply.
+type KubernetesConfig struct {
+	Kubeconfig string
+	Context    string
+}
+
+func newKubernetesRESTConfig(config *KubernetesConfig) (*rest.Config, error) {
+	if config == nil {
+		config = &KubernetesConfig{}
+	}
+	if config.Kubeconfig == "" && config.Context == "" {
+		if c, err := rest.InClusterConfig(); err == nil {
+			return c, nil
+		}
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if config.Kubeconfig != "" {
+		rules.ExplicitPath = config.Kubeconfig
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: config.Context}
+	c, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,316 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+)
+
+const (
+	NginxProviderType = "nginx"
+
+	// NginxMaxWeight is the largest sum of the weights of the servers in the upstream.
+	NginxMaxWeight = 1000
+	// NginxDefaultTotalWeight is used when NginxConfig.TotalWeight is not set.
+	NginxDefaultTotalWeight = 100
+	// NginxDefaultPIDFile is the PID file of the nginx master process that is sent SIGHUP to reload.
+	NginxDefaultPIDFile = "/var/run/nginx.pid"
+
+	nginxDefaultTimeout = 30 * time.Second
+)
+
+var (
+	// NginxDefaultTestCommand validates the configuration.
+	NginxDefaultTestCommand = []string{"nginx", "-t"}
+
+	// NginxDefaultTemplate renders only the upstream block, which is included from the nginx configuration.
+	NginxDefaultTemplate = `upstream {{ .Upstream }} {
+{{- range .Servers }}
+    server {{ .Address }}{{ if .Down }} down{{ else }} weight={{ .Weight }}{{ end }};
+{{- end }}
+}
+`
+
+	nginxUpstreamRegexp = regexp.MustCompile(`(?m)^\s*upstream\s+(\S+)\s*\{`)
+	nginxServerRegexp   = regexp.MustCompile(`^\s*server\s+(\S+)((?:\s+[^;]*)?);`)
+	nginxWeightRegexp   = regexp.MustCompile(`(?:^|\s)weight=(\d+)(?:\s|$)`)
+	nginxDownRegexp     = regexp.MustCompile(`(?:^|\s)down(?:\s|$)`)
+)
+
+type NginxConfig struct {
+	// TemplateFile is a text/template file rendered with NginxTemplateData. Defaults to NginxDefaultTemplate.
+	TemplateFile string
+	// File is where the template is rendered.
+	File     string
+	Upstream string
+	// SourceServers and DestinationServers are the addresses of the servers of the old and new groups in the upstream.
+	SourceServers      []string
+	DestinationServers []string
+
+	// TestCommand validates the rendered configuration. Defaults to NginxDefaultTestCommand.
+	TestCommand []string
+	// ReloadCommand reloads nginx. If it is not set, SIGHUP is sent to the process in PIDFile.
+	ReloadCommand []string
+	PIDFile       string
+
+	// TotalWeight is the sum of the weights of all source and destination servers.
+	TotalWeight int64
+	Timeout     time.Duration
+}
+
+// NginxServer is a server in the upstream.
+type NginxServer struct {
+	Address string
+	Weight  int64
+	// Down is true if the weight is 0, because nginx does not accept `weight=0`.
+	Down        bool
+	Destination bool
+}
+
+// NginxTemplateData is passed to the template.
+type NginxTemplateData struct {
+	Upstream string
+	// Percentage is the percentage of traffic routed to the destination.
+	Percentage         float64
+	SourceWeight       int64
+	DestinationWeight  int64
+	SourceServers      []NginxServer
+	DestinationServers []NginxServer
+	// Servers is the source servers followed by the destination servers.
+	Servers []NginxServer
+}
+
+// NginxProvider renders the weights of the servers in an upstream block from a template and reloads nginx.
+type NginxProvider struct {
+	template *template.Template
+	config   *NginxConfig
+}
+
+func (p *NginxProvider) TargetName() string {
+	return fmt.Sprintf("Nginx/%s", p.config.Upstream)
+}
+
+func (p *NginxProvider) MaxWeight() int64 {
+	return NginxMaxWeight
+}
+
+func (p *NginxProvider) TotalWeight() int64 {
+	return p.config.TotalWeight
+}
+
+// parseNginxUpstream returns the weight of each server in the upstream block. A server marked as down has no weight.
+func parseNginxUpstream(conf string, upstream string) (map[string]int64, error) {
+	for _, loc := range nginxUpstreamRegexp.FindAllStringSubmatchIndex(conf, -1) {
+		if conf[loc[2]:loc[3]] != upstream {
+			continue
+		}
+		end := strings.Index(conf[loc[1]:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("upstream `%s` is not closed", upstream)
+		}
+		weights := make(map[string]int64)
+		for _, line := range strings.Split(conf[loc[1]:loc[1]+end], "\n") {
+			m := nginxServerRegexp.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			// the weight defaults to 1
+			weight := int64(1)
+			if w := nginxWeightRegexp.FindStringSubmatch(m[2]); w != nil {
+				weight, _ = strconv.ParseInt(w[1], 10, 64)
+			}
+			if nginxDownRegexp.MatchString(m[2]) {
+				weight = 0
+			}
+			weights[m[1]] = weight
+		}
+		return weights, nil
+	}
+	return nil, fmt.Errorf("upstream `%s` is not found", upstream)
+}
+
+func (p *NginxProvider) Get() (float64, error) {
+	b, err := ioutil.ReadFile(p.config.File)
+	if err != nil {
+		return -1, err
+	}
+	weights, err := parseNginxUpstream(string(b), p.config.Upstream)
+	if err != nil {
+		return -1, fmt.Errorf("failed to parse `%s`: %w", p.config.File, err)
+	}
+
+	var src, dest int64
+	for _, server := range p.config.SourceServers {
+		w, ok := weights[server]
+		if !ok {
+			return -1, fmt.Errorf("server `%s` is not found in upstream `%s`", server, p.config.Upstream)
+		}
+		src += w
+	}
+	for _, server := range p.config.DestinationServers {
+		w, ok := weights[server]
+		if !ok {
+			return -1, fmt.Errorf("server `%s` is not found in upstream `%s`", server, p.config.Upstream)
+		}
+		dest += w
+	}
+
+	return WeightPercentage(src, dest), nil
+}
+
+func (p *NginxProvider) templateData(percentage float64) *NginxTemplateData {
+	sourceWeight, destinationWeight := DistributeWeight(percentage, p.config.TotalWeight)
+	data := &NginxTemplateData{
+		Upstream:          p.config.Upstream,
+		Percentage:        WeightPercentage(sourceWeight, destinationWeight),
+		SourceWeight:      sourceWeight,
+		DestinationWeight: destinationWeight,
+	}
+	for i, w := range divideGroupWeights(sourceWeight, len(p.config.SourceServers)) {
+		data.SourceServers = append(data.SourceServers, NginxServer{Address: p.config.SourceServers[i], Weight: w, Down: w == 0})
+	}
+	for i, w := range divideGroupWeights(destinationWeight, len(p.config.DestinationServers)) {
+		data.DestinationServers = append(data.DestinationServers, NginxServer{Address: p.config.DestinationServers[i], Weight: w, Down: w == 0, Destination: true})
+	}
+	data.Servers = append(append([]NginxServer{}, data.SourceServers...), data.DestinationServers...)
+	return data
+}
+
+func (p *NginxProvider) run(command []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("`%s` timed out after %s", strings.Join(command, " "), p.config.Timeout)
+		}
+		return fmt.Errorf("`%s` failed: %w: %s", strings.Join(command, " "), err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+func (p *NginxProvider) reload() error {
+	if len(p.config.ReloadCommand) > 0 {
+		return p.run(p.config.ReloadCommand)
+	}
+	b, err := ioutil.ReadFile(p.config.PIDFile)
+	if err != nil {
+		return err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return fmt.Errorf("invalid PID in `%s`: %w", p.config.PIDFile, err)
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Signal(syscall.SIGHUP)
+}
+
+// restore puts back the file as it was before the update. The file is removed if it did not exist.
+func (p *NginxProvider) restore(old []byte) error {
+	if old == nil {
+		return os.Remove(p.config.File)
+	}
+	return writeFileAtomically(p.config.File, old)
+}
+
+func (p *NginxProvider) Update(percentage float64) error {
+	var buf bytes.Buffer
+	if err := p.template.Execute(&buf, p.templateData(percentage)); err != nil {
+		return fmt.Errorf("failed to render the template: %w", err)
+	}
+
+	old, err := ioutil.ReadFile(p.config.File)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := writeFileAtomically(p.config.File, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write `%s`: %w", p.config.File, err)
+	}
+
+	// the file is rolled back when nginx rejects it, so that the next reload does not pick up a broken configuration
+	if err := p.run(p.config.TestCommand); err != nil {
+		if rerr := p.restore(old); rerr != nil {
+			return fmt.Errorf("%v, and failed to roll back `%s`: %w", err, p.config.File, rerr)
+		}
+		return fmt.Errorf("the rendered configuration was rolled back: %w", err)
+	}
+	if err := p.reload(); err != nil {
+		if rerr := p.restore(old); rerr != nil {
+			return fmt.Errorf("failed to reload nginx: %v, and failed to roll back `%s`: %w", err, p.config.File, rerr)
+		}
+		return fmt.Errorf("failed to reload nginx, the rendered configuration was rolled back: %w", err)
+	}
+	return nil
+}
+
+func NewNginxProvider(config *NginxConfig) (*NginxProvider, error) {
+	if config.File == "" {
+		return nil, errors.New("NginxConfig.File is missing")
+	}
+	if config.Upstream == "" {
+		return nil, errors.New("NginxConfig.Upstream is missing")
+	}
+	if len(config.SourceServers) == 0 {
+		return nil, errors.New("NginxConfig.SourceServers is missing")
+	}
+	if len(config.DestinationServers) == 0 {
+		return nil, errors.New("NginxConfig.DestinationServers is missing")
+	}
+	for _, src := range config.SourceServers {
+		for _, dest := range config.DestinationServers {
+			if src == dest {
+				return nil, fmt.Errorf("server `%s` must not be in both NginxConfig.SourceServers and NginxConfig.DestinationServers", src)
+			}
+		}
+	}
+	if config.TotalWeight == 0 {
+		config.TotalWeight = NginxDefaultTotalWeight
+	}
+	if err := validateTotalWeight("NginxConfig.TotalWeight", config.TotalWeight, NginxMaxWeight); err != nil {
+		return nil, err
+	}
+	if len(config.TestCommand) == 0 {
+		config.TestCommand = NginxDefaultTestCommand
+	}
+	if config.PIDFile == "" {
+		config.PIDFile = NginxDefaultPIDFile
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = nginxDefaultTimeout
+	}
+
+	text := NginxDefaultTemplate
+	if config.TemplateFile != "" {
+		b, err := ioutil.ReadFile(config.TemplateFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read NginxConfig.TemplateFile: %w", err)
+		}
+		text = string(b)
+	}
+	tmpl, err := template.New("nginx").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse NginxConfig.TemplateFile: %w", err)
+	}
+
+	return &NginxProvider{
+		template: tmpl,
+		config:   config,
+	}, nil
+}
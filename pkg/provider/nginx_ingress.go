@@ -0,0 +1,309 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	networkingv1client "k8s.io/client-go/kubernetes/typed/networking/v1"
+	"k8s.io/client-go/util/retry"
+	"strconv"
+)
+
+const (
+	NginxIngressProviderType = "nginx-ingress"
+
+	// NginxIngressMaxWeight is the largest total weight of the canary.
+	NginxIngressMaxWeight = 10000
+	// NginxIngressDefaultTotalWeight is used when NginxIngressConfig.TotalWeight is not set.
+	NginxIngressDefaultTotalWeight = 100
+
+	// NginxIngressCompletionKeep leaves the canary Ingress as it is.
+	NginxIngressCompletionKeep = "keep"
+	// NginxIngressCompletionRemove deletes the canary Ingress when its weight reaches 0.
+	NginxIngressCompletionRemove = "remove"
+	// NginxIngressCompletionPromote moves the backends of the canary Ingress to the
+	// primary Ingress when the weight of the canary reaches the total, and deletes the
+	// canary Ingress. It is also deleted when its weight reaches 0.
+	NginxIngressCompletionPromote = "promote"
+
+	nginxIngressCanaryAnnotation      = "nginx.ingress.kubernetes.io/canary"
+	nginxIngressWeightAnnotation      = "nginx.ingress.kubernetes.io/canary-weight"
+	nginxIngressWeightTotalAnnotation = "nginx.ingress.kubernetes.io/canary-weight-total"
+	// nginxIngressPromotedAnnotation is set to the primary Ingress when the canary was promoted,
+	// so that the destination keeps receiving all traffic after the canary Ingress is deleted.
+	nginxIngressPromotedAnnotation = "progressived.k-kinzal.github.io/promoted-canary"
+	lastAppliedConfigAnnotation    = "kubectl.kubernetes.io/last-applied-configuration"
+)
+
+type NginxIngressConfig struct {
+	Client     kubernetes.Interface
+	Kubernetes *KubernetesConfig
+
+	Namespace string
+	// PrimaryIngress is the name of the Ingress of the source.
+	PrimaryIngress string
+	// CanaryIngress is the name of the canary Ingress of the destination. Defaults to `<PrimaryIngress>-canary`.
+	CanaryIngress string
+	// CanaryService creates the canary Ingress from the primary Ingress when it
+	// does not exist, with the backend services replaced by CanaryService.
+	CanaryService string
+	// Completion is what to do with the canary Ingress at completion. Defaults to NginxIngressCompletionKeep.
+	Completion string
+
+	// TotalWeight is the weight of the canary that routes all traffic to it.
+	TotalWeight int64
+}
+
+// NginxIngressProvider manages the canary-weight annotation of an ingress-nginx canary Ingress.
+type NginxIngressProvider struct {
+	client kubernetes.Interface
+	config *NginxIngressConfig
+}
+
+func (p *NginxIngressProvider) TargetName() string {
+	return fmt.Sprintf("Kubernetes/Ingress/%s/%s", p.config.Namespace, p.config.CanaryIngress)
+}
+
+func (p *NginxIngressProvider) MaxWeight() int64 {
+	return NginxIngressMaxWeight
+}
+
+func (p *NginxIngressProvider) TotalWeight() int64 {
+	return p.config.TotalWeight
+}
+
+func (p *NginxIngressProvider) ingresses() networkingv1client.IngressInterface {
+	return p.client.NetworkingV1().Ingresses(p.config.Namespace)
+}
+
+// canaryWeight returns the weight and the total weight of the canary Ingress.
+func canaryWeight(ingress *networkingv1.Ingress) (weight int64, total int64) {
+	total = NginxIngressDefaultTotalWeight
+	if v, err := strconv.ParseInt(ingress.Annotations[nginxIngressWeightTotalAnnotation], 10, 64); err == nil && v > 0 {
+		total = v
+	}
+	if ingress.Annotations[nginxIngressCanaryAnnotation] != "true" {
+		return 0, total
+	}
+	if v, err := strconv.ParseInt(ingress.Annotations[nginxIngressWeightAnnotation], 10, 64); err == nil {
+		weight = v
+	}
+	return weight, total
+}
+
+func (p *NginxIngressProvider) Get() (float64, error) {
+	canary, err := p.ingresses().Get(context.Background(), p.config.CanaryIngress, metav1.GetOptions{})
+	if err == nil {
+		weight, total := canaryWeight(canary)
+		return WeightPercentage(total-weight, weight), nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return -1, fmt.Errorf("failed to get Ingress `%s`: %w", p.config.CanaryIngress, err)
+	}
+
+	// no canary routes all traffic to the primary unless the canary was promoted to it
+	primary, err := p.ingresses().Get(context.Background(), p.config.PrimaryIngress, metav1.GetOptions{})
+	if err != nil {
+		return -1, fmt.Errorf("failed to get Ingress `%s`: %w", p.config.PrimaryIngress, err)
+	}
+	if primary.Annotations[nginxIngressPromotedAnnotation] == p.config.CanaryIngress {
+		return 100, nil
+	}
+	return 0, nil
+}
+
+// newCanaryIngress returns the canary Ingress that routes the same hosts and paths as the primary to the canary service.
+func (p *NginxIngressProvider) newCanaryIngress(primary *networkingv1.Ingress) *networkingv1.Ingress {
+	canary := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        p.config.CanaryIngress,
+			Namespace:   primary.Namespace,
+			Labels:      primary.Labels,
+			Annotations: make(map[string]string),
+		},
+		Spec: *primary.Spec.DeepCopy(),
+	}
+	for k, v := range primary.Annotations {
+		if k == lastAppliedConfigAnnotation || k == nginxIngressPromotedAnnotation {
+			continue
+		}
+		canary.Annotations[k] = v
+	}
+	// TLS is terminated by the primary Ingress
+	canary.Spec.TLS = nil
+
+	if b := canary.Spec.DefaultBackend; b != nil && b.Service != nil {
+		b.Service.Name = p.config.CanaryService
+	}
+	for i := range canary.Spec.Rules {
+		if canary.Spec.Rules[i].HTTP == nil {
+			continue
+		}
+		for j := range canary.Spec.Rules[i].HTTP.Paths {
+			if b := canary.Spec.Rules[i].HTTP.Paths[j].Backend.Service; b != nil {
+				b.Name = p.config.CanaryService
+			}
+		}
+	}
+	return canary
+}
+
+// promote moves the backends of the canary Ingress to the primary Ingress and deletes the canary Ingress.
+func (p *NginxIngressProvider) promote(canary *networkingv1.Ingress) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		primary, err := p.ingresses().Get(context.Background(), p.config.PrimaryIngress, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get Ingress `%s`: %w", p.config.PrimaryIngress, err)
+		}
+		primary.Spec.DefaultBackend = canary.Spec.DefaultBackend
+		primary.Spec.Rules = canary.Spec.Rules
+		if primary.Annotations == nil {
+			primary.Annotations = make(map[string]string)
+		}
+		primary.Annotations[nginxIngressPromotedAnnotation] = p.config.CanaryIngress
+		_, err = p.ingresses().Update(context.Background(), primary, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to promote Ingress `%s`: %w", p.config.CanaryIngress, err)
+	}
+	return p.remove()
+}
+
+func (p *NginxIngressProvider) remove() error {
+	err := p.ingresses().Delete(context.Background(), p.config.CanaryIngress, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete Ingress `%s`: %w", p.config.CanaryIngress, err)
+	}
+	return nil
+}
+
+func (p *NginxIngressProvider) Update(percentage float64) error {
+	_, weight := DistributeWeight(percentage, p.config.TotalWeight)
+
+	var canary *networkingv1.Ingress
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		canary = nil
+		c, err := p.ingresses().Get(context.Background(), p.config.CanaryIngress, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			c, err = p.createCanary(weight)
+			if err != nil {
+				return err
+			}
+		} else if err != nil {
+			return fmt.Errorf("failed to get Ingress `%s`: %w", p.config.CanaryIngress, err)
+		}
+		if c == nil {
+			return nil
+		}
+
+		if c.Annotations == nil {
+			c.Annotations = make(map[string]string)
+		}
+		c.Annotations[nginxIngressCanaryAnnotation] = "true"
+		c.Annotations[nginxIngressWeightAnnotation] = strconv.FormatInt(weight, 10)
+		if p.config.TotalWeight != NginxIngressDefaultTotalWeight {
+			c.Annotations[nginxIngressWeightTotalAnnotation] = strconv.FormatInt(p.config.TotalWeight, 10)
+		} else {
+			delete(c.Annotations, nginxIngressWeightTotalAnnotation)
+		}
+		updated, err := p.ingresses().Update(context.Background(), c, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		canary = updated
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update Ingress `%s`: %w", p.config.CanaryIngress, err)
+	}
+	if canary == nil {
+		return nil
+	}
+
+	switch {
+	case weight == p.config.TotalWeight && p.config.Completion == NginxIngressCompletionPromote:
+		return p.promote(canary)
+	case weight == 0 && p.config.Completion != NginxIngressCompletionKeep:
+		return p.remove()
+	}
+	return nil
+}
+
+// createCanary creates the canary Ingress from the primary Ingress. It returns
+// nil if the canary Ingress is not needed because the weight is 0.
+func (p *NginxIngressProvider) createCanary(weight int64) (*networkingv1.Ingress, error) {
+	primary, err := p.ingresses().Get(context.Background(), p.config.PrimaryIngress, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Ingress `%s`: %w", p.config.PrimaryIngress, err)
+	}
+	if primary.Annotations[nginxIngressPromotedAnnotation] == p.config.CanaryIngress {
+		if weight == p.config.TotalWeight {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Ingress `%s` was already promoted to `%s`. remove the annotation `%s` from `%s` to start a new rollout", p.config.CanaryIngress, p.config.PrimaryIngress, nginxIngressPromotedAnnotation, p.config.PrimaryIngress)
+	}
+	if weight == 0 && p.config.Completion != NginxIngressCompletionKeep {
+		return nil, nil
+	}
+	if p.config.CanaryService == "" {
+		return nil, fmt.Errorf("Ingress `%s` is not found. set NginxIngressConfig.CanaryService to create it from `%s`", p.config.CanaryIngress, p.config.PrimaryIngress)
+	}
+
+	canary, err := p.ingresses().Create(context.Background(), p.newCanaryIngress(primary), metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Ingress `%s`: %w", p.config.CanaryIngress, err)
+	}
+	return canary, nil
+}
+
+func NewNginxIngressProvider(config *NginxIngressConfig) (*NginxIngressProvider, error) {
+	if config.Namespace == "" {
+		return nil, errors.New("NginxIngressConfig.Namespace is missing")
+	}
+	if config.PrimaryIngress == "" {
+		return nil, errors.New("NginxIngressConfig.PrimaryIngress is missing")
+	}
+	if config.CanaryIngress == "" {
+		config.CanaryIngress = config.PrimaryIngress + "-canary"
+	}
+	if config.CanaryIngress == config.PrimaryIngress {
+		return nil, errors.New("NginxIngressConfig.PrimaryIngress and NginxIngressConfig.CanaryIngress must be different")
+	}
+	if config.Completion == "" {
+		config.Completion = NginxIngressCompletionKeep
+	}
+	switch config.Completion {
+	case NginxIngressCompletionKeep, NginxIngressCompletionRemove, NginxIngressCompletionPromote:
+	default:
+		return nil, fmt.Errorf("NginxIngressConfig.Completion can be either \"%s\", \"%s\", \"%s\"", NginxIngressCompletionKeep, NginxIngressCompletionRemove, NginxIngressCompletionPromote)
+	}
+	if config.TotalWeight == 0 {
+		config.TotalWeight = NginxIngressDefaultTotalWeight
+	}
+	if err := validateTotalWeight("NginxIngressConfig.TotalWeight", config.TotalWeight, NginxIngressMaxWeight); err != nil {
+		return nil, err
+	}
+
+	client := config.Client
+	if client == nil {
+		c, err := newKubernetesRESTConfig(config.Kubernetes)
+		if err != nil {
+			return nil, err
+		}
+		k, err := kubernetes.NewForConfig(c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+		}
+		client = k
+	}
+
+	return &NginxIngressProvider{
+		client: client,
+		config: config,
+	}, nil
+}
@@ -0,0 +1,110 @@
+package provider_test
+
+import (
+	"context"
+	"github.com/k-kinzal/progressived/pkg/provider"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func newPrimaryIngress() *networkingv1.Ingress {
+	pathType := networkingv1.PathTypePrefix
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "app",
+			Namespace:   "default",
+			Annotations: map[string]string{"kubernetes.io/ingress.class": "nginx"},
+		},
+		Spec: networkingv1.IngressSpec{
+			TLS: []networkingv1.IngressTLS{{Hosts: []string{"example.com"}, SecretName: "tls"}},
+			Rules: []networkingv1.IngressRule{{
+				Host: "example.com",
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     "/",
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+							Name: "app-blue",
+							Port: networkingv1.ServiceBackendPort{Number: 80},
+						}},
+					}},
+				}},
+			}},
+		},
+	}
+}
+
+func newNginxIngressProvider(t *testing.T, completion string) (*provider.NginxIngressProvider, *fake.Clientset) {
+	client := fake.NewSimpleClientset(newPrimaryIngress())
+	p, err := provider.NewNginxIngressProvider(&provider.NginxIngressConfig{
+		Client:         client,
+		Namespace:      "default",
+		PrimaryIngress: "app",
+		CanaryService:  "app-green",
+		Completion:     completion,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, client
+}
+
+func TestNginxIngressProvider_Update_Promote(t *testing.T) {
+	p, client := newNginxIngressProvider(t, provider.NginxIngressCompletionPromote)
+	ingresses := client.NetworkingV1().Ingresses("default")
+
+	if err := p.Update(20); err != nil {
+		t.Fatal(err)
+	}
+	canary, err := ingresses.Get(context.Background(), "app-canary", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canary.Annotations["nginx.ingress.kubernetes.io/canary"] != "true" || canary.Annotations["nginx.ingress.kubernetes.io/canary-weight"] != "20" {
+		t.Errorf("unexpected annotations of the canary: %v", canary.Annotations)
+	}
+	if name := canary.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name; name != "app-green" {
+		t.Errorf("expected the backend of the canary to be app-green, but got %s", name)
+	}
+	if v, err := p.Get(); err != nil || v != 20 {
+		t.Errorf("expected 20, but got %f, %v", v, err)
+	}
+
+	if err := p.Update(100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ingresses.Get(context.Background(), "app-canary", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the canary to be deleted, but got %v", err)
+	}
+	primary, err := ingresses.Get(context.Background(), "app", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := primary.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name; name != "app-green" {
+		t.Errorf("expected the backend of the primary to be promoted to app-green, but got %s", name)
+	}
+	if v, err := p.Get(); err != nil || v != 100 {
+		t.Errorf("expected 100 after the promotion, but got %f, %v", v, err)
+	}
+}
+
+func TestNginxIngressProvider_Update_Remove(t *testing.T) {
+	p, client := newNginxIngressProvider(t, provider.NginxIngressCompletionRemove)
+	ingresses := client.NetworkingV1().Ingresses("default")
+
+	if err := p.Update(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ingresses.Get(context.Background(), "app-canary", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the canary to be deleted, but got %v", err)
+	}
+	if v, err := p.Get(); err != nil || v != 0 {
+		t.Errorf("expected 0, but got %f, %v", v, err)
+	}
+}
@@ -0,0 +1,168 @@
+package provider_test
+
+import (
+	"github.com/k-kinzal/progressived/pkg/provider"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNginxProvider_Update(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nginx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "upstream.conf")
+	reloaded := filepath.Join(dir, "reloaded")
+	p, err := provider.NewNginxProvider(&provider.NginxConfig{
+		File:               file,
+		Upstream:           "app",
+		SourceServers:      []string{"10.0.0.1:80", "10.0.0.2:80"},
+		DestinationServers: []string{"10.0.0.3:80"},
+		TestCommand:        []string{"true"},
+		ReloadCommand:      []string{"touch", reloaded},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(0); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `upstream app {
+    server 10.0.0.1:80 weight=50;
+    server 10.0.0.2:80 weight=50;
+    server 10.0.0.3:80 down;
+}
+`
+	if string(b) != expected {
+		t.Errorf("unexpected config file:\n%s", string(b))
+	}
+	if _, err := os.Stat(reloaded); err != nil {
+		t.Errorf("expected nginx to be reloaded: %v", err)
+	}
+	if v, err := p.Get(); err != nil || v != 0 {
+		t.Errorf("expected 0, but got %f, %v", v, err)
+	}
+
+	if err := p.Update(20); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := p.Get(); err != nil || v != 20 {
+		t.Errorf("expected 20, but got %f, %v", v, err)
+	}
+	// the remainder of the odd source weight goes to the first server
+	if err := p.Update(33); err != nil {
+		t.Fatal(err)
+	}
+	b, err = ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `upstream app {
+    server 10.0.0.1:80 weight=34;
+    server 10.0.0.2:80 weight=33;
+    server 10.0.0.3:80 weight=33;
+}
+`
+	if string(b) != expected {
+		t.Errorf("unexpected config file:\n%s", string(b))
+	}
+	if v, err := p.Get(); err != nil || v != 33 {
+		t.Errorf("expected 33, but got %f, %v", v, err)
+	}
+}
+
+func TestNginxProvider_Update_Template(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nginx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	templateFile := filepath.Join(dir, "upstream.conf.tmpl")
+	tmpl := `upstream app {
+    least_conn;
+{{- range .SourceServers }}
+    server {{ .Address }} max_fails=3 weight={{ .Weight }}{{ if .Down }} down{{ end }};
+{{- end }}
+{{- range .DestinationServers }}
+    server {{ .Address }} max_fails=3 weight={{ .Weight }}{{ if .Down }} down{{ end }};
+{{- end }}
+}
+upstream static {
+    server 10.0.1.1:80;
+}
+`
+	if err := ioutil.WriteFile(templateFile, []byte(tmpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := provider.NewNginxProvider(&provider.NginxConfig{
+		TemplateFile:       templateFile,
+		File:               filepath.Join(dir, "upstream.conf"),
+		Upstream:           "app",
+		SourceServers:      []string{"blue.internal:8080"},
+		DestinationServers: []string{"green.internal:8080"},
+		TestCommand:        []string{"true"},
+		ReloadCommand:      []string{"true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(100); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := p.Get(); err != nil || v != 100 {
+		t.Errorf("expected 100, but got %f, %v", v, err)
+	}
+}
+
+func TestNginxProvider_Update_Rollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nginx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "upstream.conf")
+	config := `upstream app {
+    server 10.0.0.1:80 weight=90;
+    server 10.0.0.2:80 weight=10;
+}
+`
+	if err := ioutil.WriteFile(file, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := provider.NewNginxProvider(&provider.NginxConfig{
+		File:               file,
+		Upstream:           "app",
+		SourceServers:      []string{"10.0.0.1:80"},
+		DestinationServers: []string{"10.0.0.2:80"},
+		TestCommand:        []string{"false"},
+		ReloadCommand:      []string{"true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(50); err == nil {
+		t.Error("expected an error because the configuration test failed")
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != config {
+		t.Errorf("expected the config file to be rolled back, but got:\n%s", string(b))
+	}
+	if v, err := p.Get(); err != nil || v != 10 {
+		t.Errorf("expected 10, but got %f, %v", v, err)
+	}
+}
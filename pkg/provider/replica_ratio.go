@@ -0,0 +1,204 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"math"
+)
+
+const (
+	ReplicaRatioProviderType = "replica-ratio"
+)
+
+type ReplicaRatioConfig struct {
+	Client     kubernetes.Interface
+	Kubernetes *KubernetesConfig
+
+	Namespace string
+	// SourceDeployment and DestinationDeployment are the Deployments behind the same Service selector.
+	SourceDeployment      string
+	DestinationDeployment string
+	// TotalReplicas is the sum of the replicas of the two Deployments. When it is
+	// missing, the current sum of the desired replicas is kept.
+	TotalReplicas int32
+}
+
+// ReplicaRatioProvider approximates the traffic split by the ratio of the replicas of two Deployments.
+type ReplicaRatioProvider struct {
+	client kubernetes.Interface
+	config *ReplicaRatioConfig
+}
+
+func (p *ReplicaRatioProvider) TargetName() string {
+	return fmt.Sprintf("Kubernetes/Deployment/%s/%s", p.config.Namespace, p.config.DestinationDeployment)
+}
+
+func (p *ReplicaRatioProvider) getDeployments() (src *appsv1.Deployment, dest *appsv1.Deployment, err error) {
+	deployments := p.client.AppsV1().Deployments(p.config.Namespace)
+	src, err = deployments.Get(context.Background(), p.config.SourceDeployment, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get Deployment `%s`: %w", p.config.SourceDeployment, err)
+	}
+	dest, err = deployments.Get(context.Background(), p.config.DestinationDeployment, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get Deployment `%s`: %w", p.config.DestinationDeployment, err)
+	}
+	return src, dest, nil
+}
+
+func desiredReplicas(d *appsv1.Deployment) int32 {
+	// the replicas defaults to 1 when it is not specified
+	if d.Spec.Replicas == nil {
+		return 1
+	}
+	return *d.Spec.Replicas
+}
+
+// totalReplicas returns the replicas to distribute, which is the current sum of the desired replicas when TotalReplicas is missing.
+func (p *ReplicaRatioProvider) totalReplicas(src *appsv1.Deployment, dest *appsv1.Deployment) int32 {
+	if p.config.TotalReplicas > 0 {
+		return p.config.TotalReplicas
+	}
+	return desiredReplicas(src) + desiredReplicas(dest)
+}
+
+func (p *ReplicaRatioProvider) MaxWeight() int64 {
+	return p.TotalWeight()
+}
+
+// TotalWeight returns the total replicas, so that a step moves at least one replica.
+func (p *ReplicaRatioProvider) TotalWeight() int64 {
+	if p.config.TotalReplicas > 0 {
+		return int64(p.config.TotalReplicas)
+	}
+	src, dest, err := p.getDeployments()
+	if err != nil {
+		// Get and Update report the error, so any total works here
+		return 1
+	}
+	if total := p.totalReplicas(src, dest); total > 0 {
+		return int64(total)
+	}
+	return 1
+}
+
+// Get returns the percentage of the desired replicas of the destination, which round-trips with Update.
+// Update waits for the replicas to be ready before the next step.
+func (p *ReplicaRatioProvider) Get() (float64, error) {
+	src, dest, err := p.getDeployments()
+	if err != nil {
+		return -1, err
+	}
+
+	return WeightPercentage(int64(desiredReplicas(src)), int64(desiredReplicas(dest))), nil
+}
+
+// distributeReplicas splits the total replicas for the percentage. The destination
+// has at least one replica once the percentage is above zero.
+func distributeReplicas(percentage float64, total int32) (source int32, destination int32) {
+	destination = int32(math.Round(percentage / 100 * float64(total)))
+	if percentage > 0 && destination < 1 {
+		destination = 1
+	}
+	if destination < 0 {
+		destination = 0
+	}
+	if destination > total {
+		destination = total
+	}
+	return total - destination, destination
+}
+
+func (p *ReplicaRatioProvider) scale(name string, replicas int32) error {
+	deployments := p.client.AppsV1().Deployments(p.config.Namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		d, err := deployments.Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if desiredReplicas(d) == replicas {
+			return nil
+		}
+		d.Spec.Replicas = &replicas
+		_, err = deployments.Update(context.Background(), d, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func (p *ReplicaRatioProvider) Update(percentage float64) error {
+	src, dest, err := p.getDeployments()
+	if err != nil {
+		return err
+	}
+	total := p.totalReplicas(src, dest)
+	if total < 1 {
+		return fmt.Errorf("Deployments `%s` and `%s` have no replicas to distribute", p.config.SourceDeployment, p.config.DestinationDeployment)
+	}
+	sourceReplicas, destinationReplicas := distributeReplicas(percentage, total)
+
+	// the traffic moves by the ready replicas, so the destination does not grow until the previous step is ready.
+	// scaling it down, such as a rollback, does not wait.
+	if destinationReplicas > desiredReplicas(dest) && dest.Status.ReadyReplicas < desiredReplicas(dest) {
+		return fmt.Errorf("Deployment `%s` has %d of %d replicas ready", p.config.DestinationDeployment, dest.Status.ReadyReplicas, desiredReplicas(dest))
+	}
+
+	// the one that scales up goes first, so that the total capacity does not drop during the update
+	order := []struct {
+		name     string
+		replicas int32
+	}{
+		{p.config.DestinationDeployment, destinationReplicas},
+		{p.config.SourceDeployment, sourceReplicas},
+	}
+	if sourceReplicas > desiredReplicas(src) {
+		order[0], order[1] = order[1], order[0]
+	}
+	for _, o := range order {
+		if err := p.scale(o.name, o.replicas); err != nil {
+			return fmt.Errorf("failed to scale Deployment `%s`: %w", o.name, err)
+		}
+	}
+
+	return nil
+}
+
+func NewReplicaRatioProvider(config *ReplicaRatioConfig) (*ReplicaRatioProvider, error) {
+	if config.Namespace == "" {
+		return nil, errors.New("ReplicaRatioConfig.Namespace is missing")
+	}
+	if config.SourceDeployment == "" {
+		return nil, errors.New("ReplicaRatioConfig.SourceDeployment is missing")
+	}
+	if config.DestinationDeployment == "" {
+		return nil, errors.New("ReplicaRatioConfig.DestinationDeployment is missing")
+	}
+	if config.SourceDeployment == config.DestinationDeployment {
+		return nil, errors.New("ReplicaRatioConfig.SourceDeployment and ReplicaRatioConfig.DestinationDeployment must be different")
+	}
+	if config.TotalReplicas < 0 {
+		return nil, errors.New("ReplicaRatioConfig.TotalReplicas must be greater than or equal to 0")
+	}
+
+	client := config.Client
+	if client == nil {
+		c, err := newKubernetesRESTConfig(config.Kubernetes)
+		if err != nil {
+			return nil, err
+		}
+		k, err := kubernetes.NewForConfig(c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+		}
+		client = k
+	}
+
+	return &ReplicaRatioProvider{
+		client: client,
+		config: config,
+	}, nil
+}
@@ -0,0 +1,162 @@
+package provider_test
+
+import (
+	"context"
+	"github.com/k-kinzal/progressived/pkg/algorithm"
+	"github.com/k-kinzal/progressived/pkg/formura"
+	"github.com/k-kinzal/progressived/pkg/metrics"
+	"github.com/k-kinzal/progressived/pkg/progressived"
+	"github.com/k-kinzal/progressived/pkg/provider"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func newDeployment(name string, replicas int32, ready int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{ReadyReplicas: ready},
+	}
+}
+
+// fakeMetrics always matches the condition `x < 1`.
+type fakeMetrics struct{}
+
+func (m *fakeMetrics) GetMetric(query string) (float64, error) {
+	return 0, nil
+}
+
+// readyAll marks the desired replicas of the Deployments as ready.
+func readyAll(t *testing.T, client *fake.Clientset, names ...string) {
+	deployments := client.AppsV1().Deployments("default")
+	for _, name := range names {
+		d, err := deployments.Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Status.ReadyReplicas = *d.Spec.Replicas
+		if _, err := deployments.Update(context.Background(), d, metav1.UpdateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReplicaRatioProvider_Update(t *testing.T) {
+	cases := []struct {
+		percentage  float64
+		source      int32
+		destination int32
+	}{
+		{1, 9, 1},
+		{25, 7, 3},
+		{50, 5, 5},
+		{100, 0, 10},
+		{0, 10, 0},
+	}
+	client := fake.NewSimpleClientset(newDeployment("app-blue", 10, 10), newDeployment("app-green", 0, 0))
+	p, err := provider.NewReplicaRatioProvider(&provider.ReplicaRatioConfig{
+		Client:                client,
+		Namespace:             "default",
+		SourceDeployment:      "app-blue",
+		DestinationDeployment: "app-green",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployments := client.AppsV1().Deployments("default")
+	for _, c := range cases {
+		if err := p.Update(c.percentage); err != nil {
+			t.Fatal(err)
+		}
+		readyAll(t, client, "app-blue", "app-green")
+		src, _ := deployments.Get(context.Background(), "app-blue", metav1.GetOptions{})
+		dest, _ := deployments.Get(context.Background(), "app-green", metav1.GetOptions{})
+		if *src.Spec.Replicas != c.source || *dest.Spec.Replicas != c.destination {
+			t.Errorf("%f: expected (%d, %d), but got (%d, %d)", c.percentage, c.source, c.destination, *src.Spec.Replicas, *dest.Spec.Replicas)
+		}
+	}
+}
+
+func TestReplicaRatioProvider_Get(t *testing.T) {
+	client := fake.NewSimpleClientset(newDeployment("app-blue", 3, 3), newDeployment("app-green", 1, 1))
+	p, err := provider.NewReplicaRatioProvider(&provider.ReplicaRatioConfig{
+		Client:                client,
+		Namespace:             "default",
+		SourceDeployment:      "app-blue",
+		DestinationDeployment: "app-green",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 25 {
+		t.Errorf("expected 25, but got %f", v)
+	}
+}
+
+func TestReplicaRatioProvider_Update_NotReady(t *testing.T) {
+	client := fake.NewSimpleClientset(newDeployment("app-blue", 3, 3), newDeployment("app-green", 1, 0))
+	p, err := provider.NewReplicaRatioProvider(&provider.ReplicaRatioConfig{
+		Client:                client,
+		Namespace:             "default",
+		SourceDeployment:      "app-blue",
+		DestinationDeployment: "app-green",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(50); err == nil {
+		t.Error("expected an error because the destination is not ready")
+	}
+	// a rollback does not wait for the destination
+	if err := p.Update(0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplicaRatioProvider_Progressived(t *testing.T) {
+	client := fake.NewSimpleClientset(newDeployment("app-blue", 4, 4), newDeployment("app-green", 0, 0))
+	prov, err := provider.NewReplicaRatioProvider(&provider.ReplicaRatioConfig{
+		Client:                client,
+		Namespace:             "default",
+		SourceDeployment:      "app-blue",
+		DestinationDeployment: "app-green",
+		TotalReplicas:         4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &progressived.Progressived{
+		Provider:  prov,
+		Metrics:   &fakeMetrics{},
+		Builder:   metrics.NewQueryBuikder("", map[string]interface{}{}),
+		Algorithm: algorithm.NewIncretion(10),
+		Formura:   formura.NewFormula("x < 1"),
+	}
+
+	// every step moves one of the 4 replicas, although it is smaller than a replica
+	for _, expected := range []float64{25, 50, 75, 100} {
+		v, err := p.Update()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != expected {
+			t.Fatalf("expected %f, but got %f", expected, v)
+		}
+		// the next step waits for the replicas
+		if _, err := p.Update(); err == nil && expected < 100 {
+			t.Fatalf("%f: expected an error because the replicas are not ready", expected)
+		}
+		readyAll(t, client, "app-blue", "app-green")
+	}
+	if _, err := p.Update(); err == nil {
+		t.Error("expected AlreadyCompletedError")
+	} else if _, ok := err.(progressived.AlreadyCompletedError); !ok {
+		t.Errorf("expected AlreadyCompletedError, but got %v", err)
+	}
+}
@@ -6,13 +6,17 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/route53"
-	"math"
 	"regexp"
 	"strings"
 )
 
 const (
 	Route53ProviderType = "route53"
+
+	// Route53MaxWeight is the largest weight Route53 accepts for a weighted record.
+	Route53MaxWeight = 255
+	// Route53DefaultTotalWeight is used when Route53Confg.TotalWeight is not set.
+	Route53DefaultTotalWeight = 100
 )
 
 type Route53Confg struct {
@@ -29,6 +33,10 @@ type Route53Confg struct {
 	SourceIdentifierRegexp      *regexp.Regexp
 	DestinationIdentifier       string
 	DestinationIdentifierRegexp *regexp.Regexp
+
+	// TotalWeight is the sum of the source and destination weights.
+	// A larger value allows finer shares, e.g. 255 for steps of about 0.4%.
+	TotalWeight int64
 }
 
 type Route53Client interface {
@@ -45,6 +53,14 @@ func (p *Route53Provider) TargetName() string {
 	return fmt.Sprintf("AWS/Route53/%s", p.config.RecordName)
 }
 
+func (p *Route53Provider) MaxWeight() int64 {
+	return Route53MaxWeight
+}
+
+func (p *Route53Provider) TotalWeight() int64 {
+	return p.config.TotalWeight
+}
+
 func (p *Route53Provider) matchPattern(substr string, r *regexp.Regexp, s string) bool {
 	if substr != "" && r != nil {
 		return strings.Index(s, substr) != -1 && r.MatchString(s)
@@ -104,7 +120,7 @@ func (p *Route53Provider) getResourceRecordSets() (src *route53.ResourceRecordSe
 		isTruncated = res.IsTruncated != nil && *res.IsTruncated == true
 	}
 	if src == nil || dest == nil {
-		return nil, nil, err
+		return nil, nil, fmt.Errorf("weighted record sets for the source and the destination were not found in `%s`", p.config.HostedZoneId)
 	}
 	return src, dest, nil
 }
@@ -112,25 +128,21 @@ func (p *Route53Provider) getResourceRecordSets() (src *route53.ResourceRecordSe
 func (p *Route53Provider) Get() (percentage float64, err error) {
 	sourceResourceRecordSet, destinationResourceRecordSet, err := p.getResourceRecordSets()
 	if err != nil {
-		return -1, nil
+		return -1, err
 	}
 
-	totalWeight := aws.Int64Value(sourceResourceRecordSet.Weight) + aws.Int64Value(destinationResourceRecordSet.Weight)
-	if totalWeight == 0 {
-		return -1, nil
-	}
-
-	return float64(aws.Int64Value(destinationResourceRecordSet.Weight)) / float64(totalWeight) * 100, nil
+	return WeightPercentage(aws.Int64Value(sourceResourceRecordSet.Weight), aws.Int64Value(destinationResourceRecordSet.Weight)), nil
 }
 
 func (p *Route53Provider) Update(percentage float64) error {
 	sourceResourceRecordSet, destinationResourceRecordSet, err := p.getResourceRecordSets()
 	if err != nil {
-		return nil
+		return err
 	}
 
-	sourceResourceRecordSet.Weight = aws.Int64(int64(100 - math.Floor(percentage)))
-	destinationResourceRecordSet.Weight = aws.Int64(int64(math.Round(percentage)))
+	sourceWeight, destinationWeight := DistributeWeight(percentage, p.config.TotalWeight)
+	sourceResourceRecordSet.Weight = aws.Int64(sourceWeight)
+	destinationResourceRecordSet.Weight = aws.Int64(destinationWeight)
 
 	input := &route53.ChangeResourceRecordSetsInput{
 		ChangeBatch: &route53.ChangeBatch{
@@ -167,7 +179,12 @@ func NewRoute53Provider(config *Route53Confg) (*Route53Provider, error) {
 	}
 	config.DestinationIdentifier = ""
 	config.DestinationIdentifierRegexp = regexp.MustCompile(`^.*$`)
-
+	if config.TotalWeight == 0 {
+		config.TotalWeight = Route53DefaultTotalWeight
+	}
+	if err := validateTotalWeight("Route53Config.TotalWeight", config.TotalWeight, Route53MaxWeight); err != nil {
+		return nil, err
+	}
 
 	client := config.Client
 
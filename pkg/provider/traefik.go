@@ -0,0 +1,452 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TraefikProviderType = "traefik"
+
+	TraefikFormatYAML = "yaml"
+	TraefikFormatTOML = "toml"
+
+	// TraefikMaxWeight is the largest total weight of the weighted service.
+	TraefikMaxWeight = 10000
+	// TraefikDefaultTotalWeight is used when TraefikConfig.TotalWeight is not set.
+	TraefikDefaultTotalWeight = 100
+
+	traefikDefaultVerifyTimeout  = 30 * time.Second
+	traefikVerifyInterval        = 500 * time.Millisecond
+	traefikDefaultRequestTimeout = 10 * time.Second
+)
+
+// traefikWeightRegexp matches the weight key and its value, e.g. `weight: 10` in YAML or `weight = 10` in TOML.
+var traefikWeightRegexp = regexp.MustCompile(`^(["']?weight["']?\s*[:=]\s*)[^\s,}#]+`)
+
+type TraefikConfig struct {
+	Client *http.Client
+
+	// File is the dynamic configuration file watched by the Traefik file provider.
+	// Update rewrites only the weight values of the source and the destination in
+	// place, so the comments and the formatting of the file are kept.
+	File string
+	// Format is either TraefikFormatYAML or TraefikFormatTOML. Defaults to the extension of File.
+	Format string
+	// ServiceName is the name of the weighted service.
+	ServiceName string
+	// SourceService and DestinationService are the names of the services in the weighted service.
+	SourceService      string
+	DestinationService string
+
+	// APIURL is the URL of the Traefik API, e.g. `http://127.0.0.1:8080`. When it
+	// is set, Update waits until Traefik loads the new weights.
+	APIURL        string
+	VerifyTimeout time.Duration
+
+	// TotalWeight is the sum of the source and destination weights.
+	TotalWeight int64
+}
+
+// TraefikProvider rewrites the weights of a weighted service in a dynamic configuration file of the Traefik file provider.
+type TraefikProvider struct {
+	client *http.Client
+	config *TraefikConfig
+}
+
+func (p *TraefikProvider) TargetName() string {
+	return fmt.Sprintf("Traefik/%s", p.config.ServiceName)
+}
+
+func (p *TraefikProvider) MaxWeight() int64 {
+	return TraefikMaxWeight
+}
+
+func (p *TraefikProvider) TotalWeight() int64 {
+	return p.config.TotalWeight
+}
+
+// traefikServiceWeight is the weight of a service in the weighted service and where it is written in the file.
+// The lines and columns start from 1.
+type traefikServiceWeight struct {
+	name   string
+	weight int64
+	// line and column of the weight key, or 0 when the weight is not set
+	line   int
+	column int
+	// line and column of the name key, after which the weight is added when it is not set
+	nameLine   int
+	nameColumn int
+	// inline is set when the service is written in a single line, e.g. `{name: app, weight: 1}`
+	inline bool
+}
+
+// traefikWeightedService is the services in a weighted service of the configuration file.
+type traefikWeightedService interface {
+	service(name string) (*traefikServiceWeight, error)
+}
+
+type traefikYAMLService struct {
+	services *yaml.Node
+	config   *TraefikConfig
+}
+
+// yamlLookup returns the key and the value of the mapping node.
+func yamlLookup(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func (s *traefikYAMLService) service(name string) (*traefikServiceWeight, error) {
+	for _, service := range s.services.Content {
+		nameKey, nameValue := yamlLookup(service, "name")
+		if nameValue == nil || nameValue.Value != name {
+			continue
+		}
+		// the weight defaults to 1 in Traefik
+		w := &traefikServiceWeight{
+			name:       name,
+			weight:     1,
+			nameLine:   nameKey.Line,
+			nameColumn: nameKey.Column,
+			inline:     service.Style&yaml.FlowStyle != 0,
+		}
+		if weightKey, weightValue := yamlLookup(service, "weight"); weightValue != nil {
+			if err := weightValue.Decode(&w.weight); err != nil {
+				return nil, fmt.Errorf("weight of service `%s` must be an integer: %w", name, err)
+			}
+			w.line, w.column = weightKey.Line, weightKey.Column
+		}
+		return w, nil
+	}
+	return nil, fmt.Errorf("service `%s` is not found in weighted service `%s`", name, s.config.ServiceName)
+}
+
+func parseTraefikYAML(b []byte, config *TraefikConfig) (*traefikYAMLService, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("weighted service `%s` is not found", config.ServiceName)
+	}
+	node := doc.Content[0]
+	for _, key := range []string{"http", "services", config.ServiceName, "weighted", "services"} {
+		if _, node = yamlLookup(node, key); node == nil {
+			return nil, fmt.Errorf("weighted service `%s` is not found", config.ServiceName)
+		}
+	}
+	if node.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("services of weighted service `%s` must be a list", config.ServiceName)
+	}
+	return &traefikYAMLService{services: node, config: config}, nil
+}
+
+type traefikTOMLService struct {
+	services []*toml.Tree
+	config   *TraefikConfig
+}
+
+func (s *traefikTOMLService) service(name string) (*traefikServiceWeight, error) {
+	for _, service := range s.services {
+		if n, _ := service.Get("name").(string); n != name {
+			continue
+		}
+		// the weight defaults to 1 in Traefik
+		pos := service.GetPosition("name")
+		w := &traefikServiceWeight{
+			name:       name,
+			weight:     1,
+			nameLine:   pos.Line,
+			nameColumn: pos.Col,
+		}
+		if service.Has("weight") {
+			weight, ok := service.Get("weight").(int64)
+			if !ok {
+				return nil, fmt.Errorf("weight of service `%s` must be an integer", name)
+			}
+			pos := service.GetPosition("weight")
+			w.weight, w.line, w.column = weight, pos.Line, pos.Col
+		}
+		return w, nil
+	}
+	return nil, fmt.Errorf("service `%s` is not found in weighted service `%s`", name, s.config.ServiceName)
+}
+
+func parseTraefikTOML(b []byte, config *TraefikConfig) (*traefikTOMLService, error) {
+	tree, err := toml.LoadBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	// the service name may contain dots, so the keys are not joined
+	services, ok := tree.GetPath([]string{"http", "services", config.ServiceName, "weighted", "services"}).([]*toml.Tree)
+	if !ok {
+		return nil, fmt.Errorf("weighted service `%s` is not found", config.ServiceName)
+	}
+	return &traefikTOMLService{services: services, config: config}, nil
+}
+
+// setTraefikWeights rewrites only the weight values in the lines of the file, or adds the weight after the name
+// of a service that has none, so that the comments and the formatting of the rest of the file are kept.
+func setTraefikWeights(b []byte, format string, weights map[*traefikServiceWeight]int64) ([]byte, error) {
+	services := make([]*traefikServiceWeight, 0, len(weights))
+	for w := range weights {
+		services = append(services, w)
+	}
+	// the lines are edited from the bottom so that an added line does not move the others
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].nameLine > services[j].nameLine
+	})
+
+	lines := strings.Split(string(b), "\n")
+	for _, w := range services {
+		weight := strconv.FormatInt(weights[w], 10)
+		if w.line > 0 {
+			if w.line > len(lines) {
+				return nil, fmt.Errorf("weight of service `%s` is not found at line %d", w.name, w.line)
+			}
+			line := []rune(lines[w.line-1])
+			if w.column < 1 || w.column > len(line) {
+				return nil, fmt.Errorf("weight of service `%s` is not found at line %d", w.name, w.line)
+			}
+			rest := string(line[w.column-1:])
+			loc := traefikWeightRegexp.FindStringSubmatchIndex(rest)
+			if loc == nil {
+				return nil, fmt.Errorf("weight of service `%s` is not found at line %d", w.name, w.line)
+			}
+			lines[w.line-1] = string(line[:w.column-1]) + rest[:loc[3]] + weight + rest[loc[1]:]
+			continue
+		}
+
+		if w.nameLine < 1 || w.nameLine > len(lines) {
+			return nil, fmt.Errorf("service `%s` is not found at line %d", w.name, w.nameLine)
+		}
+		line := []rune(lines[w.nameLine-1])
+		if w.nameColumn < 1 || w.nameColumn > len(line) {
+			return nil, fmt.Errorf("service `%s` is not found at line %d", w.name, w.nameLine)
+		}
+		prefix := line[:w.nameColumn-1]
+		if w.inline || strings.ContainsRune(string(prefix), '{') {
+			return nil, fmt.Errorf("weight cannot be added to service `%s` written in a line, set the weight in the file first", w.name)
+		}
+		// the weight is indented like the name, e.g. under `- name: app` in YAML
+		indent := make([]rune, len(prefix))
+		for i, r := range prefix {
+			if r == '\t' {
+				indent[i] = r
+			} else {
+				indent[i] = ' '
+			}
+		}
+		added := string(indent) + "weight: " + weight
+		if format == TraefikFormatTOML {
+			added = string(indent) + "weight = " + weight
+		}
+		lines = append(lines[:w.nameLine], append([]string{added}, lines[w.nameLine:]...)...)
+	}
+
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+func (p *TraefikProvider) load() ([]byte, traefikWeightedService, error) {
+	b, err := ioutil.ReadFile(p.config.File)
+	if err != nil {
+		return nil, nil, err
+	}
+	var s traefikWeightedService
+	if p.config.Format == TraefikFormatTOML {
+		s, err = parseTraefikTOML(b, p.config)
+	} else {
+		s, err = parseTraefikYAML(b, p.config)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse `%s`: %w", p.config.File, err)
+	}
+	return b, s, nil
+}
+
+func (p *TraefikProvider) services(s traefikWeightedService) (src *traefikServiceWeight, dest *traefikServiceWeight, err error) {
+	src, err = s.service(p.config.SourceService)
+	if err != nil {
+		return nil, nil, err
+	}
+	dest, err = s.service(p.config.DestinationService)
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, dest, nil
+}
+
+func (p *TraefikProvider) Get() (float64, error) {
+	_, s, err := p.load()
+	if err != nil {
+		return -1, err
+	}
+	src, dest, err := p.services(s)
+	if err != nil {
+		return -1, err
+	}
+
+	return WeightPercentage(src.weight, dest.weight), nil
+}
+
+type traefikServiceInfo struct {
+	Weighted struct {
+		Services []struct {
+			Name   string `json:"name"`
+			Weight *int64 `json:"weight"`
+		} `json:"services"`
+	} `json:"weighted"`
+}
+
+// loadedWeights returns the weights of the weighted service that Traefik loaded.
+func (p *TraefikProvider) loadedWeights() (src int64, dest int64, err error) {
+	u := fmt.Sprintf("%s/api/http/services/%s", p.config.APIURL, url.PathEscape(p.config.ServiceName+"@file"))
+	res, err := p.client.Get(u)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return 0, 0, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("unexpected status code %d: %s", res.StatusCode, strings.TrimSpace(string(b)))
+	}
+
+	var info traefikServiceInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		return 0, 0, err
+	}
+	src, dest = -1, -1
+	for _, s := range info.Weighted.Services {
+		// the weight defaults to 1 when it is not specified
+		weight := int64(1)
+		if s.Weight != nil {
+			weight = *s.Weight
+		}
+		switch s.Name {
+		case p.config.SourceService:
+			src = weight
+		case p.config.DestinationService:
+			dest = weight
+		}
+	}
+	return src, dest, nil
+}
+
+func (p *TraefikProvider) verify(src int64, dest int64) error {
+	deadline := time.Now().Add(p.config.VerifyTimeout)
+	for {
+		loadedSrc, loadedDest, err := p.loadedWeights()
+		if err == nil && loadedSrc == src && loadedDest == dest {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("Traefik did not load the weights of `%s` within %s: %w", p.config.ServiceName, p.config.VerifyTimeout, err)
+			}
+			return fmt.Errorf("Traefik did not load the weights of `%s` within %s: expected (%d, %d), but got (%d, %d)", p.config.ServiceName, p.config.VerifyTimeout, src, dest, loadedSrc, loadedDest)
+		}
+		time.Sleep(traefikVerifyInterval)
+	}
+}
+
+func (p *TraefikProvider) Update(percentage float64) error {
+	sourceWeight, destinationWeight := DistributeWeight(percentage, p.config.TotalWeight)
+
+	b, s, err := p.load()
+	if err != nil {
+		return err
+	}
+	src, dest, err := p.services(s)
+	if err != nil {
+		return err
+	}
+	b, err = setTraefikWeights(b, p.config.Format, map[*traefikServiceWeight]int64{
+		src:  sourceWeight,
+		dest: destinationWeight,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set the weights in `%s`: %w", p.config.File, err)
+	}
+	if err := writeFileAtomically(p.config.File, b); err != nil {
+		return fmt.Errorf("failed to write `%s`: %w", p.config.File, err)
+	}
+
+	if p.config.APIURL != "" {
+		return p.verify(sourceWeight, destinationWeight)
+	}
+	return nil
+}
+
+func NewTraefikProvider(config *TraefikConfig) (*TraefikProvider, error) {
+	if config.File == "" {
+		return nil, errors.New("TraefikConfig.File is missing")
+	}
+	if config.ServiceName == "" {
+		return nil, errors.New("TraefikConfig.ServiceName is missing")
+	}
+	if config.SourceService == "" {
+		return nil, errors.New("TraefikConfig.SourceService is missing")
+	}
+	if config.DestinationService == "" {
+		return nil, errors.New("TraefikConfig.DestinationService is missing")
+	}
+	if config.SourceService == config.DestinationService {
+		return nil, errors.New("TraefikConfig.SourceService and TraefikConfig.DestinationService must be different")
+	}
+	if config.Format == "" {
+		switch strings.ToLower(filepath.Ext(config.File)) {
+		case ".toml":
+			config.Format = TraefikFormatTOML
+		default:
+			config.Format = TraefikFormatYAML
+		}
+	}
+	switch config.Format {
+	case TraefikFormatYAML, TraefikFormatTOML:
+	default:
+		return nil, fmt.Errorf("TraefikConfig.Format can be either \"%s\", \"%s\"", TraefikFormatYAML, TraefikFormatTOML)
+	}
+	if config.TotalWeight == 0 {
+		config.TotalWeight = TraefikDefaultTotalWeight
+	}
+	if err := validateTotalWeight("TraefikConfig.TotalWeight", config.TotalWeight, TraefikMaxWeight); err != nil {
+		return nil, err
+	}
+	if config.VerifyTimeout <= 0 {
+		config.VerifyTimeout = traefikDefaultVerifyTimeout
+	}
+	config.APIURL = strings.TrimSuffix(config.APIURL, "/")
+
+	client := config.Client
+	if client == nil {
+		client = &http.Client{Timeout: traefikDefaultRequestTimeout}
+	}
+
+	return &TraefikProvider{
+		client: client,
+		config: config,
+	}, nil
+}
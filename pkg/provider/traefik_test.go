@@ -0,0 +1,336 @@
+package provider_test
+
+import (
+	"encoding/json"
+	"github.com/k-kinzal/progressived/pkg/provider"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraefikProvider_Update_YAML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "traefik")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "dynamic.yml")
+	config := `http:
+  routers:
+    web:
+      rule: Host(` + "`example.com`" + `)
+      service: app
+  services:
+    app:
+      weighted:
+        services:
+        - name: app-v1
+          weight: 100
+        - name: app-v2
+          weight: 0
+    app-v1:
+      loadBalancer:
+        servers:
+        - url: http://10.0.0.1/
+`
+	if err := ioutil.WriteFile(file, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the Traefik API serves the weights in the file, as if it loaded the file
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/http/services/app@file" {
+			http.NotFound(w, r)
+			return
+		}
+		p, err := provider.NewTraefikProvider(&provider.TraefikConfig{
+			File:               file,
+			ServiceName:        "app",
+			SourceService:      "app-v1",
+			DestinationService: "app-v2",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		v, err := p.Get()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"weighted": map[string]interface{}{
+				"services": []map[string]interface{}{
+					{"name": "app-v1", "weight": 100 - v},
+					{"name": "app-v2", "weight": v},
+				},
+			},
+		})
+	}))
+	defer server.Close()
+
+	p, err := provider.NewTraefikProvider(&provider.TraefikConfig{
+		File:               file,
+		ServiceName:        "app",
+		SourceService:      "app-v1",
+		DestinationService: "app-v2",
+		APIURL:             server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(30); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := p.Get(); err != nil || v != 30 {
+		t.Errorf("expected 30, but got %f, %v", v, err)
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "- url: http://10.0.0.1/") || !strings.Contains(string(b), "rule: Host(`example.com`)") {
+		t.Errorf("expected the other fields to be preserved, but got:\n%s", string(b))
+	}
+}
+
+func TestTraefikProvider_Update_TOML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "traefik")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "dynamic.toml")
+	config := `[http.routers.web]
+  rule = "Host(` + "`example.com`" + `)"
+  service = "app"
+
+[[http.services.app.weighted.services]]
+  name = "app-v1"
+  weight = 3
+
+[[http.services.app.weighted.services]]
+  name = "app-v2"
+`
+	if err := ioutil.WriteFile(file, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := provider.NewTraefikProvider(&provider.TraefikConfig{
+		File:               file,
+		ServiceName:        "app",
+		SourceService:      "app-v1",
+		DestinationService: "app-v2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the weight defaults to 1
+	if v, err := p.Get(); err != nil || v != 25 {
+		t.Errorf("expected 25, but got %f, %v", v, err)
+	}
+	if err := p.Update(60); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := p.Get(); err != nil || v != 60 {
+		t.Errorf("expected 60, but got %f, %v", v, err)
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected the file mode to be preserved, but got %s", info.Mode())
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "Host(`example.com`)") {
+		t.Errorf("expected the other fields to be preserved, but got:\n%s", string(b))
+	}
+}
+
+func TestTraefikProvider_Update_VerifyTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "traefik")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "dynamic.yaml")
+	config := `http:
+  services:
+    app:
+      weighted:
+        services:
+        - name: app-v1
+          weight: 100
+        - name: app-v2
+          weight: 0
+`
+	if err := ioutil.WriteFile(file, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Traefik keeps serving the old weights
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"weighted":{"services":[{"name":"app-v1","weight":100},{"name":"app-v2","weight":0}]}}`))
+	}))
+	defer server.Close()
+
+	p, err := provider.NewTraefikProvider(&provider.TraefikConfig{
+		File:               file,
+		ServiceName:        "app",
+		SourceService:      "app-v1",
+		DestinationService: "app-v2",
+		APIURL:             server.URL,
+		VerifyTimeout:      time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(50); err == nil {
+		t.Error("expected an error because Traefik did not load the weights")
+	}
+}
+
+func TestTraefikProvider_Update_PreservesFile(t *testing.T) {
+	cases := []struct {
+		file     string
+		config   string
+		expected string
+	}{
+		{
+			file: "dynamic.yaml",
+			config: `# managed by hand
+http:
+  services:
+    app:
+      weighted:
+        services:
+        - name: app-v1 # stable
+          weight: 100
+        - name: app-v2
+    app-v1:
+      loadBalancer:
+        servers: [{url: "http://10.0.0.1/"}]
+`,
+			expected: `# managed by hand
+http:
+  services:
+    app:
+      weighted:
+        services:
+        - name: app-v1 # stable
+          weight: 70
+        - name: app-v2
+          weight: 30
+    app-v1:
+      loadBalancer:
+        servers: [{url: "http://10.0.0.1/"}]
+`,
+		},
+		{
+			file: "dynamic.toml",
+			config: `# managed by hand
+[[http.services.app.weighted.services]]
+	name = "app-v1"
+	weight = 100 # stable
+
+[[http.services.app.weighted.services]]
+	name = "app-v2"
+`,
+			expected: `# managed by hand
+[[http.services.app.weighted.services]]
+	name = "app-v1"
+	weight = 70 # stable
+
+[[http.services.app.weighted.services]]
+	name = "app-v2"
+	weight = 30
+`,
+		},
+	}
+	for _, c := range cases {
+		dir, err := ioutil.TempDir("", "traefik")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		file := filepath.Join(dir, c.file)
+		if err := ioutil.WriteFile(file, []byte(c.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		p, err := provider.NewTraefikProvider(&provider.TraefikConfig{
+			File:               file,
+			ServiceName:        "app",
+			SourceService:      "app-v1",
+			DestinationService: "app-v2",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Update(30); err != nil {
+			t.Fatalf("%s: %v", c.file, err)
+		}
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != c.expected {
+			t.Errorf("%s: expected only the weights to be changed, but got:\n%s", c.file, string(b))
+		}
+		if v, err := p.Get(); err != nil || v != 30 {
+			t.Errorf("%s: expected 30, but got %f, %v", c.file, v, err)
+		}
+	}
+}
+
+func TestTraefikProvider_Update_InlineService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "traefik")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "dynamic.yaml")
+	config := `http:
+  services:
+    app:
+      weighted:
+        services: [{name: app-v1, weight: 100}, {name: app-v2}]
+`
+	if err := ioutil.WriteFile(file, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := provider.NewTraefikProvider(&provider.TraefikConfig{
+		File:               file,
+		ServiceName:        "app",
+		SourceService:      "app-v1",
+		DestinationService: "app-v2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(30); err == nil {
+		t.Error("expected an error because the weight cannot be added to the service written in a line")
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != config {
+		t.Errorf("expected the file not to be changed, but got:\n%s", string(b))
+	}
+}
@@ -0,0 +1,176 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/vpclattice"
+	"strings"
+)
+
+const (
+	VPCLatticeProviderType = "vpclattice"
+
+	// VPCLatticeMaxWeight is the largest weight VPC Lattice accepts for a target group.
+	VPCLatticeMaxWeight = 999
+	// VPCLatticeDefaultTotalWeight is used when VPCLatticeConfig.TotalWeight is not set.
+	VPCLatticeDefaultTotalWeight = 100
+)
+
+type VPCLatticeConfig struct {
+	Sess *session.Session
+
+	Client VPCLatticeClient
+
+	// ServiceIdentifier, ListenerIdentifier and RuleIdentifier are IDs or ARNs of the listener rule.
+	ServiceIdentifier  string
+	ListenerIdentifier string
+	RuleIdentifier     string
+	// SourceTargetGroup and DestinationTargetGroup are IDs or ARNs of the target groups the rule forwards to.
+	SourceTargetGroup      string
+	DestinationTargetGroup string
+
+	// TotalWeight is the sum of the source and destination weights.
+	TotalWeight int64
+}
+
+type VPCLatticeClient interface {
+	GetRule(input *vpclattice.GetRuleInput) (*vpclattice.GetRuleOutput, error)
+	UpdateRule(input *vpclattice.UpdateRuleInput) (*vpclattice.UpdateRuleOutput, error)
+}
+
+// VPCLatticeProvider shifts the weight between two target groups in the forward action of a VPC Lattice listener rule.
+type VPCLatticeProvider struct {
+	client VPCLatticeClient
+	config *VPCLatticeConfig
+}
+
+func (p *VPCLatticeProvider) TargetName() string {
+	return fmt.Sprintf("AWS/VPCLattice/%s/%s/%s", p.config.ServiceIdentifier, p.config.ListenerIdentifier, p.config.RuleIdentifier)
+}
+
+func (p *VPCLatticeProvider) MaxWeight() int64 {
+	return VPCLatticeMaxWeight
+}
+
+func (p *VPCLatticeProvider) TotalWeight() int64 {
+	return p.config.TotalWeight
+}
+
+// matchVPCLatticeIdentifier reports whether the two identifiers refer to the same resource. Either may be an ARN that ends with the ID.
+func matchVPCLatticeIdentifier(a string, b string) bool {
+	return a == b || strings.HasSuffix(a, "/"+b) || strings.HasSuffix(b, "/"+a)
+}
+
+func (p *VPCLatticeProvider) getRuleAction() (*vpclattice.RuleAction, error) {
+	res, err := p.client.GetRule(&vpclattice.GetRuleInput{
+		ServiceIdentifier:  aws.String(p.config.ServiceIdentifier),
+		ListenerIdentifier: aws.String(p.config.ListenerIdentifier),
+		RuleIdentifier:     aws.String(p.config.RuleIdentifier),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Action == nil || res.Action.Forward == nil {
+		return nil, fmt.Errorf("rule `%s` does not forward to target groups", p.config.RuleIdentifier)
+	}
+	return res.Action, nil
+}
+
+func (p *VPCLatticeProvider) findTargetGroups(action *vpclattice.RuleAction) (src *vpclattice.WeightedTargetGroup, dest *vpclattice.WeightedTargetGroup, err error) {
+	for _, tg := range action.Forward.TargetGroups {
+		id := aws.StringValue(tg.TargetGroupIdentifier)
+		switch {
+		case matchVPCLatticeIdentifier(id, p.config.SourceTargetGroup):
+			src = tg
+		case matchVPCLatticeIdentifier(id, p.config.DestinationTargetGroup):
+			dest = tg
+		}
+	}
+	if src == nil || dest == nil {
+		return nil, nil, fmt.Errorf("target groups `%s` and `%s` were not found in rule `%s`", p.config.SourceTargetGroup, p.config.DestinationTargetGroup, p.config.RuleIdentifier)
+	}
+	return src, dest, nil
+}
+
+func (p *VPCLatticeProvider) Get() (float64, error) {
+	action, err := p.getRuleAction()
+	if err != nil {
+		return -1, err
+	}
+	src, dest, err := p.findTargetGroups(action)
+	if err != nil {
+		return -1, err
+	}
+
+	return WeightPercentage(aws.Int64Value(src.Weight), aws.Int64Value(dest.Weight)), nil
+}
+
+func (p *VPCLatticeProvider) Update(percentage float64) error {
+	action, err := p.getRuleAction()
+	if err != nil {
+		return err
+	}
+	src, dest, err := p.findTargetGroups(action)
+	if err != nil {
+		return err
+	}
+
+	sourceWeight, destinationWeight := DistributeWeight(percentage, p.config.TotalWeight)
+	src.Weight = aws.Int64(sourceWeight)
+	dest.Weight = aws.Int64(destinationWeight)
+
+	input := &vpclattice.UpdateRuleInput{
+		ServiceIdentifier:  aws.String(p.config.ServiceIdentifier),
+		ListenerIdentifier: aws.String(p.config.ListenerIdentifier),
+		RuleIdentifier:     aws.String(p.config.RuleIdentifier),
+		Action:             action,
+	}
+	if _, err := p.client.UpdateRule(input); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func NewVPCLatticeProvider(config *VPCLatticeConfig) (*VPCLatticeProvider, error) {
+	if config.ServiceIdentifier == "" {
+		return nil, errors.New("VPCLatticeConfig.ServiceIdentifier is missing")
+	}
+	if config.ListenerIdentifier == "" {
+		return nil, errors.New("VPCLatticeConfig.ListenerIdentifier is missing")
+	}
+	if config.RuleIdentifier == "" {
+		return nil, errors.New("VPCLatticeConfig.RuleIdentifier is missing")
+	}
+	if config.SourceTargetGroup == "" {
+		return nil, errors.New("VPCLatticeConfig.SourceTargetGroup is missing")
+	}
+	if config.DestinationTargetGroup == "" {
+		return nil, errors.New("VPCLatticeConfig.DestinationTargetGroup is missing")
+	}
+	if matchVPCLatticeIdentifier(config.SourceTargetGroup, config.DestinationTargetGroup) {
+		return nil, errors.New("VPCLatticeConfig.SourceTargetGroup and VPCLatticeConfig.DestinationTargetGroup must be different")
+	}
+	if config.TotalWeight == 0 {
+		config.TotalWeight = VPCLatticeDefaultTotalWeight
+	}
+	if err := validateTotalWeight("VPCLatticeConfig.TotalWeight", config.TotalWeight, VPCLatticeMaxWeight); err != nil {
+		return nil, err
+	}
+
+	client := config.Client
+
+	if client == nil {
+		if config.Sess == nil {
+			return nil, errors.New("VPCLatticeConfig.Sess must be set when VPCLatticeConfig.Client is missing")
+		}
+		client = vpclattice.New(config.Sess)
+	}
+
+	return &VPCLatticeProvider{
+		client: client,
+		config: config,
+	}, nil
+}
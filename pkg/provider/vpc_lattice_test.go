@@ -0,0 +1,82 @@
+package provider_test
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/vpclattice"
+	"github.com/k-kinzal/progressived/pkg/provider"
+	"testing"
+)
+
+type fakeVPCLatticeClient struct {
+	action *vpclattice.RuleAction
+	input  *vpclattice.UpdateRuleInput
+}
+
+func (c *fakeVPCLatticeClient) GetRule(input *vpclattice.GetRuleInput) (*vpclattice.GetRuleOutput, error) {
+	return &vpclattice.GetRuleOutput{Action: c.action}, nil
+}
+
+func (c *fakeVPCLatticeClient) UpdateRule(input *vpclattice.UpdateRuleInput) (*vpclattice.UpdateRuleOutput, error) {
+	c.action = input.Action
+	c.input = input
+	return &vpclattice.UpdateRuleOutput{Action: c.action}, nil
+}
+
+func TestVPCLatticeProvider_Update(t *testing.T) {
+	client := &fakeVPCLatticeClient{
+		action: &vpclattice.RuleAction{
+			Forward: &vpclattice.ForwardAction{
+				TargetGroups: []*vpclattice.WeightedTargetGroup{
+					{TargetGroupIdentifier: aws.String("tg-0123456789abcdef0"), Weight: aws.Int64(100)},
+					{TargetGroupIdentifier: aws.String("tg-0fedcba9876543210"), Weight: aws.Int64(0)},
+				},
+			},
+		},
+	}
+	p, err := provider.NewVPCLatticeProvider(&provider.VPCLatticeConfig{
+		Client:                 client,
+		ServiceIdentifier:      "svc-0123456789abcdef0",
+		ListenerIdentifier:     "listener-0123456789abcdef0",
+		RuleIdentifier:         "rule-0123456789abcdef0",
+		SourceTargetGroup:      "arn:aws:vpc-lattice:us-east-1:123456789012:targetgroup/tg-0123456789abcdef0",
+		DestinationTargetGroup: "tg-0fedcba9876543210",
+		TotalWeight:            200,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(12.5); err != nil {
+		t.Fatal(err)
+	}
+	if aws.StringValue(client.input.RuleIdentifier) != "rule-0123456789abcdef0" {
+		t.Errorf("unexpected rule identifier: %s", aws.StringValue(client.input.RuleIdentifier))
+	}
+	if w := aws.Int64Value(client.action.Forward.TargetGroups[0].Weight); w != 175 {
+		t.Errorf("expected the weight of the source to be 175, but got %d", w)
+	}
+	if v, err := p.Get(); err != nil || v != 12.5 {
+		t.Errorf("expected 12.5, but got %f, %v", v, err)
+	}
+}
+
+func TestVPCLatticeProvider_Get_FixedResponse(t *testing.T) {
+	client := &fakeVPCLatticeClient{
+		action: &vpclattice.RuleAction{
+			FixedResponse: &vpclattice.FixedResponseAction{StatusCode: aws.Int64(404)},
+		},
+	}
+	p, err := provider.NewVPCLatticeProvider(&provider.VPCLatticeConfig{
+		Client:                 client,
+		ServiceIdentifier:      "svc-0123456789abcdef0",
+		ListenerIdentifier:     "listener-0123456789abcdef0",
+		RuleIdentifier:         "rule-0123456789abcdef0",
+		SourceTargetGroup:      "tg-0123456789abcdef0",
+		DestinationTargetGroup: "tg-0fedcba9876543210",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Get(); err == nil {
+		t.Error("expected an error because the rule does not forward to target groups")
+	}
+}
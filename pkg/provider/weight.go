@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"fmt"
+	"math"
+)
+
+// WeightedProvider is a provider that splits traffic between the source and
+// the destination with integer weights.
+type WeightedProvider interface {
+	Provider
+	// MaxWeight returns the largest weight that can be set on a single target.
+	MaxWeight() int64
+	// TotalWeight returns the sum of the source and destination weights written by Update.
+	TotalWeight() int64
+}
+
+// QuantizePercentage rounds the percentage to the nearest value that can be
+// expressed with totalWeight, so that it round-trips through Update and Get.
+func QuantizePercentage(percentage float64, totalWeight int64) float64 {
+	_, dest := DistributeWeight(percentage, totalWeight)
+	return WeightPercentage(totalWeight-dest, dest)
+}
+
+// QuantizeStep is QuantizePercentage for a step from current to percentage.
+// A step smaller than one weight unit would be rounded back to current, so it
+// moves by one unit toward percentage instead.
+func QuantizeStep(current float64, percentage float64, totalWeight int64) float64 {
+	q := QuantizePercentage(percentage, totalWeight)
+	if q != QuantizePercentage(current, totalWeight) || percentage == current {
+		return q
+	}
+	_, dest := DistributeWeight(current, totalWeight)
+	if percentage > current && dest < totalWeight {
+		dest++
+	}
+	if percentage < current && dest > 0 {
+		dest--
+	}
+	return WeightPercentage(totalWeight-dest, dest)
+}
+
+// DistributeWeight splits totalWeight into the source and destination weights
+// for the percentage. The two weights always sum to totalWeight.
+func DistributeWeight(percentage float64, totalWeight int64) (source int64, destination int64) {
+	destination = int64(math.Round(percentage / 100 * float64(totalWeight)))
+	if destination < 0 {
+		destination = 0
+	}
+	if destination > totalWeight {
+		destination = totalWeight
+	}
+	return totalWeight - destination, destination
+}
+
+// WeightPercentage returns the percentage of traffic routed to the destination.
+// It returns -1 if both weights are zero.
+func WeightPercentage(source int64, destination int64) float64 {
+	total := source + destination
+	if total == 0 {
+		return -1
+	}
+	return float64(destination) / float64(total) * 100
+}
+
+func validateTotalWeight(name string, totalWeight int64, maxWeight int64) error {
+	if totalWeight <= 0 {
+		return fmt.Errorf("%s must be greater than 0", name)
+	}
+	if totalWeight > maxWeight {
+		return fmt.Errorf("%s must be less than or equal to %d", name, maxWeight)
+	}
+	return nil
+}
+
+// divideGroupWeights divides the weight of a group among n servers. The first servers take the
+// remainder, so that the weights sum to the weight of the group and round-trip through Get. A server has
+// a weight of 0 when the group has less weight than servers.
+func divideGroupWeights(groupWeight int64, n int) []int64 {
+	weights := make([]int64, n)
+	for i := range weights {
+		weights[i] = groupWeight / int64(n)
+		if int64(i) < groupWeight%int64(n) {
+			weights[i]++
+		}
+	}
+	return weights
+}
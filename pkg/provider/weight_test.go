@@ -0,0 +1,65 @@
+package provider_test
+
+import (
+	"testing"
+
+	"github.com/k-kinzal/progressived/pkg/provider"
+)
+
+func TestDistributeWeight(t *testing.T) {
+	cases := []struct {
+		percentage  float64
+		totalWeight int64
+		source      int64
+		destination int64
+	}{
+		{0, 100, 100, 0},
+		{10.5, 100, 89, 11},
+		{100, 100, 0, 100},
+		{0.5, 255, 254, 1},
+		{0.5, 1000, 995, 5},
+		{-1, 255, 255, 0},
+		{101, 255, 0, 255},
+	}
+	for _, c := range cases {
+		src, dest := provider.DistributeWeight(c.percentage, c.totalWeight)
+		if src != c.source || dest != c.destination {
+			t.Errorf("DistributeWeight(%f, %d) = (%d, %d), want (%d, %d)", c.percentage, c.totalWeight, src, dest, c.source, c.destination)
+		}
+		if src+dest != c.totalWeight {
+			t.Errorf("DistributeWeight(%f, %d) weights sum to %d", c.percentage, c.totalWeight, src+dest)
+		}
+	}
+}
+
+func TestQuantizePercentage_RoundTrip(t *testing.T) {
+	for _, total := range []int64{100, 255, 1000} {
+		for pct := 0.0; pct <= 100; pct += 0.1 {
+			q := provider.QuantizePercentage(pct, total)
+			src, dest := provider.DistributeWeight(q, total)
+			if got := provider.WeightPercentage(src, dest); got != q {
+				t.Fatalf("total %d: %f quantized to %f but round-trips to %f", total, pct, q, got)
+			}
+		}
+	}
+}
+
+func TestQuantizeStep(t *testing.T) {
+	cases := []struct {
+		current    float64
+		percentage float64
+		expected   float64
+	}{
+		{20, 30, 30},
+		{20, 20.3, 21},
+		{20, 19.7, 19},
+		{100, 100, 100},
+		{0, 0, 0},
+		{99.6, 100, 100},
+	}
+	for _, c := range cases {
+		if got := provider.QuantizeStep(c.current, c.percentage, 100); got != c.expected {
+			t.Errorf("QuantizeStep(%f, %f, 100) = %f, expected %f", c.current, c.percentage, got, c.expected)
+		}
+	}
+}